@@ -21,8 +21,10 @@ import (
 	"log/slog"
 	"math"
 	"math/bits"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
 	"os/signal"
@@ -30,6 +32,8 @@ import (
 	goregexp "regexp" //nolint:depguard // The Prometheus client library requires us to pass a regexp from this package.
 	"runtime"
 	"runtime/debug"
+	runtime_pprof "runtime/pprof"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -62,6 +66,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/metadata"
 	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
 	_ "github.com/prometheus/prometheus/plugins" // Register plugins.
 	"github.com/prometheus/prometheus/promql"
@@ -172,7 +177,9 @@ func agentOnlyFlag(app *kingpin.Application, name, help string) *kingpin.FlagCla
 }
 
 type flagConfig struct {
-	configFile string
+	configFile     string
+	dumpConfigFile string
+	readOnly       bool
 
 	agentStoragePath            string
 	serverStoragePath           string
@@ -188,13 +195,19 @@ type flagConfig struct {
 	lookbackDelta               model.Duration
 	webTimeout                  model.Duration
 	queryTimeout                model.Duration
+	queryPerClientBudget        model.Duration
 	queryConcurrency            int
 	queryMaxSamples             int
 	RemoteFlushDeadline         model.Duration
+	remoteMaxShards             int
 	maxNotificationsSubscribers int
+	tsdbSnapshotInterval        model.Duration
+	tsdbSnapshotRetention       int
 
 	enableAutoReload   bool
 	autoReloadInterval model.Duration
+	reloadMinInterval  model.Duration
+	checkConfig        bool
 
 	maxprocsEnable bool
 	memlimitEnable bool
@@ -208,6 +221,7 @@ type flagConfig struct {
 
 	prometheusURL   string
 	corsRegexString string
+	responseHeaders []string
 
 	promqlEnableDelayedNameRemoval bool
 
@@ -322,9 +336,18 @@ func main() {
 	a.Flag("config.file", "Prometheus configuration file path.").
 		Default("prometheus.yml").StringVar(&cfg.configFile)
 
+	a.Flag("config.dump-file", "If set, the fully-resolved configuration is written to this file as YAML after each successful reload. Intended for debugging; secrets are redacted.").
+		Default("").StringVar(&cfg.dumpConfigFile)
+
 	a.Flag("config.auto-reload-interval", "Specifies the interval for checking and automatically reloading the Prometheus configuration file upon detecting changes.").
 		Default("30s").SetValue(&cfg.autoReloadInterval)
 
+	a.Flag("config.reload-min-interval", "Minimum interval between configuration reloads triggered via SIGHUP or the reload endpoint. Requests arriving within the interval are coalesced into a single subsequent reload. Use 0 to reload immediately on every request.").
+		Default("0s").SetValue(&cfg.reloadMinInterval)
+
+	a.Flag("config.check", "Validate the configuration file and referenced rule files, then exit. The exit code indicates success (0) or invalid configuration (1). The TSDB is not opened and no ports are bound.").
+		Default("false").BoolVar(&cfg.checkConfig)
+
 	a.Flag("web.listen-address", "Address to listen on for UI, API, and telemetry. Can be repeated.").
 		Default("0.0.0.0:9090").StringsVar(&cfg.web.ListenAddresses)
 
@@ -347,6 +370,9 @@ func main() {
 	a.Flag("web.max-connections", "Maximum number of simultaneous connections across all listeners.").
 		Default("512").IntVar(&cfg.web.MaxConnections)
 
+	a.Flag("web.max-connections-per-ip", "Maximum number of simultaneous connections from a single remote IP address. New connections from an address over its quota are rejected. 0 means unlimited.").
+		Default("0").IntVar(&cfg.web.MaxConnectionsPerIP)
+
 	a.Flag("web.max-notifications-subscribers", "Limits the maximum number of subscribers that can concurrently receive live notifications. If the limit is reached, new subscription requests will be denied until existing connections close.").
 		Default("16").IntVar(&cfg.maxNotificationsSubscribers)
 
@@ -364,6 +390,9 @@ func main() {
 	a.Flag("web.enable-lifecycle", "Enable shutdown and reload via HTTP request.").
 		Default("false").BoolVar(&cfg.web.EnableLifecycle)
 
+	serverOnlyFlag(a, "web.read-only", "Serve queries, federation and the UI only: no scraping, rule evaluation or alerting takes place and the lifecycle and admin APIs are rejected. Useful when fronting historical blocks.").
+		Default("false").BoolVar(&cfg.readOnly)
+
 	a.Flag("web.enable-admin-api", "Enable API endpoints for admin control actions.").
 		Default("false").BoolVar(&cfg.web.EnableAdminAPI)
 
@@ -391,6 +420,9 @@ func main() {
 	a.Flag("web.cors.origin", `Regex for CORS origin. It is fully anchored. Example: 'https?://(domain1|domain2)\.com'`).
 		Default(".*").StringVar(&cfg.corsRegexString)
 
+	a.Flag("web.response-header", "Static HTTP header added to every web response, in 'Name:Value' form. Can be repeated. Hop-by-hop headers are not allowed.").
+		PlaceHolder("<name>:<value>").StringsVar(&cfg.responseHeaders)
+
 	serverOnlyFlag(a, "storage.tsdb.path", "Base path for metrics storage.").
 		Default("data/").StringVar(&cfg.serverStoragePath)
 
@@ -418,6 +450,9 @@ func main() {
 	serverOnlyFlag(a, "storage.tsdb.no-lockfile", "Do not create lockfile in data directory.").
 		Default("false").BoolVar(&cfg.tsdb.NoLockfile)
 
+	serverOnlyFlag(a, "storage.tsdb.max-sample-age", "Maximum age of samples accepted into the TSDB. Samples with a timestamp older than now minus this age are rejected as out of bounds. 0 disables the check.").
+		Default("0s").SetValue(&cfg.tsdb.MaxSampleAge)
+
 	serverOnlyFlag(a, "storage.tsdb.allow-overlapping-compaction", "Allow compaction of overlapping blocks. If set to false, TSDB stops vertical compaction and leaves overlapping blocks there. The use case is to let another component handle the compaction of overlapping blocks.").
 		Default("true").Hidden().BoolVar(&cfg.tsdb.EnableOverlappingCompaction)
 
@@ -436,6 +471,12 @@ func main() {
 	serverOnlyFlag(a, "storage.tsdb.delayed-compaction.max-percent", "Sets the upper limit for the random compaction delay, specified as a percentage of the head chunk range. 100 means the compaction can be delayed by up to the entire head chunk range. Only effective when the delayed-compaction feature flag is enabled.").
 		Default("10").Hidden().IntVar(&cfg.tsdb.CompactionDelayMaxPercent)
 
+	serverOnlyFlag(a, "storage.tsdb.snapshot-interval", "How often to automatically snapshot the TSDB into the snapshots directory for backups. 0 disables periodic snapshots.").
+		Default("0s").SetValue(&cfg.tsdbSnapshotInterval)
+
+	serverOnlyFlag(a, "storage.tsdb.snapshot-retention", "Number of periodic snapshots to retain. Older periodic snapshots are removed after a new one is taken; snapshots taken through the admin API are not affected. 0 disables pruning.").
+		Default("3").IntVar(&cfg.tsdbSnapshotRetention)
+
 	agentOnlyFlag(a, "storage.agent.path", "Base path for metrics storage.").
 		Default("data-agent/").StringVar(&cfg.agentStoragePath)
 
@@ -467,6 +508,9 @@ func main() {
 	a.Flag("storage.remote.flush-deadline", "How long to wait flushing sample on shutdown or config reload.").
 		Default("1m").PlaceHolder("<duration>").SetValue(&cfg.RemoteFlushDeadline)
 
+	a.Flag("storage.remote.max-shards", "Maximum number of concurrent remote write shards per queue, overriding queue configurations asking for more. 0 means use the per-queue configuration as is.").
+		Default("0").IntVar(&cfg.remoteMaxShards)
+
 	serverOnlyFlag(a, "storage.remote.read-sample-limit", "Maximum overall number of samples to return via the remote read interface, in a single query. 0 means no limit. This limit is ignored for streamed response types.").
 		Default("5e7").IntVar(&cfg.web.RemoteReadSampleLimit)
 
@@ -506,12 +550,18 @@ func main() {
 	serverOnlyFlag(a, "query.timeout", "Maximum time a query may take before being aborted.").
 		Default("2m").SetValue(&cfg.queryTimeout)
 
+	serverOnlyFlag(a, "query.per-client-budget", "Maximum total query execution time a single client may consume over a one-minute sliding window before new queries are rejected with 429. 0 disables the budget.").
+		Default("0s").SetValue(&cfg.queryPerClientBudget)
+
 	serverOnlyFlag(a, "query.max-concurrency", "Maximum number of queries executed concurrently.").
 		Default("20").IntVar(&cfg.queryConcurrency)
 
 	serverOnlyFlag(a, "query.max-samples", "Maximum number of samples a single query can load into memory. Note that queries will fail if they try to load more samples than this into memory, so this also limits the number of samples a query can return.").
 		Default("50000000").IntVar(&cfg.queryMaxSamples)
 
+	serverOnlyFlag(a, "query.align-steps-to-epoch", "Snap range query start and end timestamps down to multiples of the step relative to the Unix epoch before evaluation. Off by default to preserve the exact requested timestamps.").
+		Default("false").BoolVar(&cfg.web.AlignStepsToEpoch)
+
 	a.Flag("scrape.discovery-reload-interval", "Interval used by scrape manager to throttle target groups updates.").
 		Hidden().Default("5s").SetValue(&cfg.scrape.DiscoveryReloadInterval)
 
@@ -583,6 +633,17 @@ func main() {
 		os.Exit(2)
 	}
 
+	cfg.web.ResponseHeaders, err = parseResponseHeaders(cfg.responseHeaders)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("invalid --web.response-header: %w", err))
+		os.Exit(2)
+	}
+
+	// Config check mode: validate and exit without starting the server.
+	if cfg.checkConfig {
+		os.Exit(checkConfigFile(logger, cfg.configFile, agentMode))
+	}
+
 	// Throw error for invalid config before starting other components.
 	var cfgFile *config.Config
 	if cfgFile, err = config.LoadFile(cfg.configFile, agentMode, promslog.NewNopLogger()); err != nil {
@@ -620,13 +681,28 @@ func main() {
 	configSuccess.Set(1)
 	configSuccessTime.SetToCurrentTime()
 
+	if cfg.readOnly {
+		if cfg.web.EnableLifecycle || cfg.web.EnableAdminAPI {
+			logger.Warn("Read-only mode disables the lifecycle and admin APIs")
+		}
+		cfg.web.EnableLifecycle = false
+		cfg.web.EnableAdminAPI = false
+		logger.Info("Read-only mode enabled; scrape, rule and notifier managers will not run")
+	}
+
 	cfg.web.ReadTimeout = time.Duration(cfg.webTimeout)
+	cfg.web.PerClientQueryBudget = time.Duration(cfg.queryPerClientBudget)
 	// Default -web.route-prefix to path of -web.external-url.
+	explicitRoutePrefix := cfg.web.RoutePrefix != ""
 	if cfg.web.RoutePrefix == "" {
 		cfg.web.RoutePrefix = cfg.web.ExternalURL.Path
 	}
 	// RoutePrefix must always be at least '/'.
 	cfg.web.RoutePrefix = "/" + strings.Trim(cfg.web.RoutePrefix, "/")
+	if explicitRoutePrefix && !routePrefixMatchesExternalURLPath(cfg.web.RoutePrefix, cfg.web.ExternalURL.Path) {
+		logger.Warn("--web.route-prefix does not match the path of --web.external-url; relative links generated from the external URL will not resolve under the served prefix unless the reverse proxy rewrites it",
+			"route_prefix", cfg.web.RoutePrefix, "external_url_path", cfg.web.ExternalURL.Path)
+	}
 
 	if !agentMode {
 		if cfg.tsdb.RetentionDuration == 0 && cfg.tsdb.MaxBytes == 0 {
@@ -696,6 +772,7 @@ func main() {
 		remoteStorage = remote.NewStorage(logger.With("component", "remote"), prometheus.DefaultRegisterer, localStorage.StartTime, localStoragePath, time.Duration(cfg.RemoteFlushDeadline), scraper, cfg.scrape.AppendMetadata)
 		fanoutStorage = storage.NewFanout(logger, localStorage, remoteStorage)
 	)
+	remoteStorage.SetMaxShards(cfg.remoteMaxShards)
 
 	var (
 		ctxWeb, cancelWeb = context.WithCancel(context.Background())
@@ -901,7 +978,13 @@ func main() {
 		}, {
 			// The Scrape and notifier managers need to reload before the Discovery manager as
 			// they need to read the most updated config when receiving the new targets list.
-			name:     "scrape",
+			name: "scrape",
+			validator: func(cfg *config.Config) error {
+				// Resolving the scrape config files surfaces broken ones
+				// before any other component has applied the new config.
+				_, err := cfg.GetScrapeConfigs()
+				return err
+			},
 			reloader: scrapeManager.ApplyConfig,
 		}, {
 			name: "scrape_sd",
@@ -1031,6 +1114,33 @@ func main() {
 			},
 		)
 	}
+	if len(debugDumpSignals) > 0 {
+		// Debug profile dump handler. Signal-triggered so profiles can be
+		// captured even when the web handler is unresponsive.
+		dumpc := make(chan os.Signal, 1)
+		signal.Notify(dumpc, debugDumpSignals...)
+		cancel := make(chan struct{})
+		g.Add(
+			func() error {
+				for {
+					select {
+					case <-dumpc:
+						path, err := writeDebugProfiles(localStoragePath, time.Now())
+						if err != nil {
+							logger.Error("Failed to write debug profiles", "err", err)
+							continue
+						}
+						logger.Info("Wrote debug profiles", "path", path)
+					case <-cancel:
+						return nil
+					}
+				}
+			},
+			func(err error) {
+				close(cancel)
+			},
+		)
+	}
 	{
 		// Scrape discovery manager.
 		g.Add(
@@ -1059,7 +1169,7 @@ func main() {
 			},
 		)
 	}
-	if !agentMode {
+	if !agentMode && !cfg.readOnly {
 		// Rule manager.
 		g.Add(
 			func() error {
@@ -1072,7 +1182,7 @@ func main() {
 			},
 		)
 	}
-	{
+	if !cfg.readOnly {
 		// Scrape manager.
 		g.Add(
 			func() error {
@@ -1134,14 +1244,38 @@ func main() {
 			notifs.AddNotification(notifications.ConfigurationUnsuccessful)
 		}
 
+		limiter := newReloadRateLimiter(time.Duration(cfg.reloadMinInterval))
+		var pendingReload <-chan time.Time
+		if time.Duration(cfg.reloadMinInterval) > 0 {
+			pendingReload = time.Tick(time.Duration(cfg.reloadMinInterval))
+		}
+
 		g.Add(
 			func() error {
 				<-reloadReady.C
 
+				// Reply channels of coalesced web reload requests. They are
+				// answered with the result of the reload that eventually runs
+				// so that /-/reload does not report success before the
+				// configuration has actually been applied.
+				var pendingReplies []chan error
+				replyPending := func(err error) {
+					for _, rc := range pendingReplies {
+						rc <- err
+					}
+					pendingReplies = nil
+				}
+
 				for {
 					select {
 					case <-hup:
-						if err := reloadConfig(cfg.configFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, callback, reloaders...); err != nil {
+						if !limiter.request() {
+							logger.Info("Reload request coalesced", "min_interval", cfg.reloadMinInterval)
+							continue
+						}
+						err := reloadConfig(cfg.configFile, cfg.dumpConfigFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, callback, reloaders...)
+						replyPending(err)
+						if err != nil {
 							logger.Error("Error reloading config", "err", err)
 						} else if cfg.enableAutoReload {
 							checksum, err = config.GenerateChecksum(cfg.configFile)
@@ -1150,16 +1284,34 @@ func main() {
 							}
 						}
 					case rc := <-webHandler.Reload():
-						if err := reloadConfig(cfg.configFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, callback, reloaders...); err != nil {
+						if !limiter.request() {
+							logger.Info("Reload request coalesced", "min_interval", cfg.reloadMinInterval)
+							pendingReplies = append(pendingReplies, rc)
+							continue
+						}
+						err := reloadConfig(cfg.configFile, cfg.dumpConfigFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, callback, reloaders...)
+						rc <- err
+						replyPending(err)
+						if err != nil {
 							logger.Error("Error reloading config", "err", err)
-							rc <- err
-						} else {
-							rc <- nil
-							if cfg.enableAutoReload {
-								checksum, err = config.GenerateChecksum(cfg.configFile)
-								if err != nil {
-									logger.Error("Failed to generate checksum during configuration reload", "err", err)
-								}
+						} else if cfg.enableAutoReload {
+							checksum, err = config.GenerateChecksum(cfg.configFile)
+							if err != nil {
+								logger.Error("Failed to generate checksum during configuration reload", "err", err)
+							}
+						}
+					case <-pendingReload:
+						if !limiter.takePending() {
+							continue
+						}
+						err := reloadConfig(cfg.configFile, cfg.dumpConfigFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, callback, reloaders...)
+						replyPending(err)
+						if err != nil {
+							logger.Error("Error reloading config", "err", err)
+						} else if cfg.enableAutoReload {
+							checksum, err = config.GenerateChecksum(cfg.configFile)
+							if err != nil {
+								logger.Error("Failed to generate checksum during configuration reload", "err", err)
 							}
 						}
 					case <-time.Tick(time.Duration(cfg.autoReloadInterval)):
@@ -1175,12 +1327,13 @@ func main() {
 						}
 						logger.Info("Configuration file change detected, reloading the configuration.")
 
-						if err := reloadConfig(cfg.configFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, callback, reloaders...); err != nil {
+						if err := reloadConfig(cfg.configFile, cfg.dumpConfigFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, callback, reloaders...); err != nil {
 							logger.Error("Error reloading config", "err", err)
 						} else {
 							checksum = currentChecksum
 						}
 					case <-cancel:
+						replyPending(errors.New("server is shutting down"))
 						return nil
 					}
 				}
@@ -1205,7 +1358,7 @@ func main() {
 					return nil
 				}
 
-				if err := reloadConfig(cfg.configFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, func(bool) {}, reloaders...); err != nil {
+				if err := reloadConfig(cfg.configFile, cfg.dumpConfigFile, cfg.tsdb.EnableExemplarStorage, logger, noStepSubqueryInterval, func(bool) {}, reloaders...); err != nil {
 					return fmt.Errorf("error loading config from %q: %w", cfg.configFile, err)
 				}
 
@@ -1278,6 +1431,36 @@ func main() {
 			},
 		)
 	}
+	if !agentMode && cfg.tsdbSnapshotInterval > 0 {
+		// Periodic TSDB snapshots.
+		cancel := make(chan struct{})
+		g.Add(
+			func() error {
+				ticker := time.NewTicker(time.Duration(cfg.tsdbSnapshotInterval))
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						db, ok := localStorage.get().(*tsdb.DB)
+						if !ok {
+							continue
+						}
+						name, err := takeDBSnapshot(db, cfg.tsdbSnapshotRetention)
+						if err != nil {
+							logger.Error("Failed to create periodic TSDB snapshot", "err", err)
+							continue
+						}
+						logger.Info("Created periodic TSDB snapshot", "name", name)
+					case <-cancel:
+						return nil
+					}
+				}
+			},
+			func(err error) {
+				close(cancel)
+			},
+		)
+	}
 	if agentMode {
 		// WAL storage.
 		opts := cfg.agent.ToAgentOptions(cfg.tsdb.OutOfOrderTimeWindow)
@@ -1347,7 +1530,7 @@ func main() {
 			},
 		)
 	}
-	{
+	if !cfg.readOnly {
 		// Notifier.
 
 		// Calling notifier.Stop() before ruleManager.Stop() will cause a panic if the ruleManager isn't running,
@@ -1378,6 +1561,60 @@ func main() {
 	logger.Info("See you next time!")
 }
 
+// periodicSnapshotPrefix marks snapshots taken by the periodic snapshotter.
+// Only snapshots carrying it are subject to retention pruning; snapshots
+// taken through the admin API are left alone.
+const periodicSnapshotPrefix = "periodic-"
+
+// takeDBSnapshot writes a new snapshot of db into its snapshots directory,
+// named like snapshots taken through the admin API but with a prefix marking
+// it as periodic, and prunes the oldest periodic snapshots beyond the given
+// retention count.
+func takeDBSnapshot(db *tsdb.DB, retention int) (string, error) {
+	snapdir := filepath.Join(db.Dir(), "snapshots")
+	name := fmt.Sprintf("%s%s-%016x",
+		periodicSnapshotPrefix,
+		time.Now().UTC().Format("20060102T150405Z0700"),
+		rand.Int63())
+	dir := filepath.Join(snapdir, name)
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return "", fmt.Errorf("create snapshot directory: %w", err)
+	}
+	if err := db.Snapshot(dir, true); err != nil {
+		return "", fmt.Errorf("create snapshot: %w", err)
+	}
+	return name, pruneSnapshots(snapdir, retention)
+}
+
+// pruneSnapshots removes the oldest periodic snapshot directories in snapdir
+// so that at most retention of them remain. Directories without the periodic
+// prefix, such as admin API snapshots, are never touched. Periodic snapshot
+// names start with a timestamp after the prefix, so lexical order is creation
+// order.
+func pruneSnapshots(snapdir string, retention int) error {
+	if retention <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(snapdir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), periodicSnapshotPrefix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for len(names) > retention {
+		if err := os.RemoveAll(filepath.Join(snapdir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
 func openDBWithMetrics(dir string, logger *slog.Logger, reg prometheus.Registerer, opts *tsdb.Options, stats *tsdb.DBStats) (*tsdb.DB, error) {
 	db, err := tsdb.Open(
 		dir,
@@ -1432,9 +1669,88 @@ func (i *safePromQLNoStepSubqueryInterval) Get(int64) int64 {
 type reloader struct {
 	name     string
 	reloader func(*config.Config) error
+	// validator, if set, is called with the new config before any reloader
+	// applies it. If any validator rejects the config, no component is
+	// touched, preventing a half-applied reload.
+	validator func(*config.Config) error
+}
+
+// reloadRateLimiter coalesces configuration reload requests so that at most
+// one reload runs per interval. Requests arriving while the limiter is
+// closed collapse into a single pending reload that becomes due once the
+// interval has passed. A zero interval disables limiting.
+type reloadRateLimiter struct {
+	interval time.Duration
+	last     time.Time
+	pending  bool
+	now      func() time.Time // Injectable for tests.
+}
+
+func newReloadRateLimiter(interval time.Duration) *reloadRateLimiter {
+	return &reloadRateLimiter{interval: interval, now: time.Now}
+}
+
+// request records a reload request and reports whether it may run
+// immediately. When it returns false the request is remembered and a later
+// takePending call returns true once the interval has passed.
+func (r *reloadRateLimiter) request() bool {
+	if r.interval <= 0 {
+		return true
+	}
+	if now := r.now(); now.Sub(r.last) >= r.interval {
+		r.last = now
+		r.pending = false
+		return true
+	}
+	r.pending = true
+	return false
+}
+
+// takePending reports whether a coalesced reload is due and, if so, claims it.
+func (r *reloadRateLimiter) takePending() bool {
+	if !r.pending {
+		return false
+	}
+	now := r.now()
+	if now.Sub(r.last) < r.interval {
+		return false
+	}
+	r.last = now
+	r.pending = false
+	return true
+}
+
+// checkConfigFile validates the configuration file and every rule file it
+// references without starting the server. It returns the process exit code:
+// 0 when everything is valid, 1 otherwise.
+func checkConfigFile(logger *slog.Logger, filename string, agentMode bool) int {
+	conf, err := config.LoadFile(filename, agentMode, promslog.NewNopLogger())
+	if err != nil {
+		logger.Error("Configuration is invalid", "file", filename, "err", err)
+		return 1
+	}
+	if _, err := conf.GetScrapeConfigs(); err != nil {
+		logger.Error("Scrape config files referenced by the configuration are invalid", "file", filename, "err", err)
+		return 1
+	}
+	for _, pat := range conf.RuleFiles {
+		fns, err := filepath.Glob(pat)
+		if err != nil {
+			logger.Error("Bad rule file pattern in configuration", "pattern", pat, "err", err)
+			return 1
+		}
+		for _, fn := range fns {
+			if _, errs := rulefmt.ParseFile(fn); len(errs) > 0 {
+				logger.Error("Rule file is invalid", "file", fn, "err", errors.Join(errs...))
+				return 1
+			}
+		}
+	}
+	logger.Info("Configuration is valid", "file", filename)
+	return 0
 }
 
-func reloadConfig(filename string, enableExemplarStorage bool, logger *slog.Logger, noStepSuqueryInterval *safePromQLNoStepSubqueryInterval, callback func(bool), rls ...reloader) (err error) {
+func reloadConfig(filename, dumpFilename string, enableExemplarStorage bool, logger *slog.Logger, noStepSuqueryInterval *safePromQLNoStepSubqueryInterval, callback func(bool), rls ...reloader) (err error) {
 	start := time.Now()
 	timingsLogger := logger
 	logger.Info("Loading configuration file", "filename", filename)
@@ -1461,6 +1777,19 @@ func reloadConfig(filename string, enableExemplarStorage bool, logger *slog.Logg
 		}
 	}
 
+	// Validation phase: give every reloader that supports it a chance to
+	// reject the new config before any component applies it, so a bad config
+	// cannot leave the components in a mixed state.
+	for _, rl := range rls {
+		if rl.validator == nil {
+			continue
+		}
+		if err := rl.validator(conf); err != nil {
+			logger.Error("Failed to validate configuration", "name", rl.name, "err", err)
+			return fmt.Errorf("configuration rejected by %q during validation (--config.file=%q)", rl.name, filename)
+		}
+	}
+
 	failed := false
 	for _, rl := range rls {
 		rstart := time.Now()
@@ -1485,11 +1814,62 @@ func reloadConfig(filename string, enableExemplarStorage bool, logger *slog.Logg
 		os.Setenv("GOGC", "off")
 	}
 
+	if dumpFilename != "" {
+		if err := dumpConfig(conf, dumpFilename); err != nil {
+			logger.Error("Failed to write resolved configuration", "file", dumpFilename, "err", err)
+		}
+	}
+
 	noStepSuqueryInterval.Set(conf.GlobalConfig.EvaluationInterval)
 	timingsLogger.Info("Completed loading of configuration file", "filename", filename, "totalDuration", time.Since(start))
 	return nil
 }
 
+// writeDebugProfiles writes a heap profile and a goroutine dump into dir,
+// using ts for unique, sortable file names. It returns the path of the heap
+// profile.
+func writeDebugProfiles(dir string, ts time.Time) (string, error) {
+	stamp := ts.UTC().Format("20060102T150405Z")
+	heapPath := filepath.Join(dir, "heap-"+stamp+".pprof")
+	for _, p := range []struct{ profile, path string }{
+		{"heap", heapPath},
+		{"goroutine", filepath.Join(dir, "goroutine-"+stamp+".pprof")},
+	} {
+		f, err := os.Create(p.path)
+		if err != nil {
+			return "", err
+		}
+		if err := runtime_pprof.Lookup(p.profile).WriteTo(f, 0); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+	}
+	return heapPath, nil
+}
+
+// dumpConfig writes the fully-resolved configuration to filename as YAML,
+// atomically via a temporary file and rename.
+func dumpConfig(conf *config.Config, filename string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.WriteString(conf.String()); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
 func startsOrEndsWithQuote(s string) bool {
 	return strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") ||
 		strings.HasSuffix(s, "\"") || strings.HasSuffix(s, "'")
@@ -1504,6 +1884,41 @@ func compileCORSRegexString(s string) (*regexp.Regexp, error) {
 	return r.Regexp, nil
 }
 
+// hopByHopHeaders are headers that must not be set as static response
+// headers because they are meaningful only for a single transport-level
+// connection (RFC 7230, section 6.1).
+var hopByHopHeaders = map[string]struct{}{
+	"Connection":          {},
+	"Keep-Alive":          {},
+	"Proxy-Authenticate":  {},
+	"Proxy-Authorization": {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Transfer-Encoding":   {},
+	"Upgrade":             {},
+}
+
+// parseResponseHeaders parses 'Name:Value' specs from --web.response-header
+// into the static header map served by the web handler.
+func parseResponseHeaders(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, ":")
+		name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected 'Name:Value', got %q", spec)
+		}
+		if _, ok := hopByHopHeaders[name]; ok {
+			return nil, fmt.Errorf("hop-by-hop header %q is not allowed", name)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
 // computeExternalURL computes a sanitized external URL from a raw input. It infers unset
 // URL parts from the OS and the given listen address.
 func computeExternalURL(u, listenAddr string) (*url.URL, error) {
@@ -1537,6 +1952,16 @@ func computeExternalURL(u, listenAddr string) (*url.URL, error) {
 	return eu, nil
 }
 
+// routePrefixMatchesExternalURLPath reports whether an explicitly set route
+// prefix agrees with the path of the external URL. An external URL without a
+// path is compatible with any prefix.
+func routePrefixMatchesExternalURLPath(routePrefix, externalURLPath string) bool {
+	if externalURLPath == "" || externalURLPath == "/" {
+		return true
+	}
+	return routePrefix == "/"+strings.Trim(externalURLPath, "/")
+}
+
 // readyStorage implements the Storage interface while allowing to set the actual
 // storage at a later point in time.
 type readyStorage struct {
@@ -1679,6 +2104,22 @@ func (s *readyStorage) Close() error {
 	return nil
 }
 
+// CloseWithTimeout closes the storage but gives up waiting after the given
+// duration. The underlying close keeps running in the background, so a
+// timeout error means resources may still be releasing.
+func (s *readyStorage) CloseWithTimeout(d time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Close()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(d):
+		return fmt.Errorf("storage did not close within %s; resources may still be releasing", d)
+	}
+}
+
 // CleanTombstones implements the api_v1.TSDBAdminStats and api_v2.TSDBAdmin interfaces.
 func (s *readyStorage) CleanTombstones() error {
 	if x := s.get(); x != nil {
@@ -1791,6 +2232,7 @@ type tsdbOptions struct {
 	StripeSize                     int
 	MinBlockDuration               model.Duration
 	MaxBlockDuration               model.Duration
+	MaxSampleAge                   model.Duration
 	OutOfOrderTimeWindow           int64
 	EnableExemplarStorage          bool
 	MaxExemplars                   int64
@@ -1815,6 +2257,7 @@ func (opts tsdbOptions) ToTSDBOptions() tsdb.Options {
 		StripeSize:                     opts.StripeSize,
 		MinBlockDuration:               int64(time.Duration(opts.MinBlockDuration) / time.Millisecond),
 		MaxBlockDuration:               int64(time.Duration(opts.MaxBlockDuration) / time.Millisecond),
+		MaxSampleAge:                   int64(time.Duration(opts.MaxSampleAge) / time.Millisecond),
 		EnableExemplarStorage:          opts.EnableExemplarStorage,
 		MaxExemplars:                   opts.MaxExemplars,
 		EnableMemorySnapshotOnShutdown: opts.EnableMemorySnapshotOnShutdown,
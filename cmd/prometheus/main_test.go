@@ -38,8 +38,11 @@ import (
 
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/prometheus/prometheus/notifier"
 	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
 )
 
 func init() {
@@ -120,6 +123,33 @@ func TestComputeExternalURL(t *testing.T) {
 	}
 }
 
+func TestRoutePrefixMatchesExternalURLPath(t *testing.T) {
+	tests := []struct {
+		routePrefix     string
+		externalURLPath string
+		match           bool
+	}{
+		// An external URL without a path agrees with any prefix.
+		{"/", "", true},
+		{"/prometheus", "", true},
+		{"/", "/", true},
+		// Matching paths, with and without trailing slashes.
+		{"/prometheus", "/prometheus", true},
+		{"/prometheus", "/prometheus/", true},
+		// Mismatches, including a bare prefix behind a pathed external URL.
+		{"/", "/prometheus", false},
+		{"/prom", "/prometheus", false},
+		{"/prometheus/sub", "/prometheus", false},
+	}
+
+	for _, test := range tests {
+		u, err := computeExternalURL("http://example.com"+test.externalURLPath, "0.0.0.0:9090")
+		require.NoError(t, err)
+		require.Equal(t, test.match, routePrefixMatchesExternalURLPath(test.routePrefix, u.Path),
+			"route_prefix=%q external_url_path=%q", test.routePrefix, test.externalURLPath)
+	}
+}
+
 // Let's provide an invalid configuration file and verify the exit status indicates the error.
 func TestFailedStartupExitCode(t *testing.T) {
 	if testing.Short() {
@@ -140,6 +170,84 @@ func TestFailedStartupExitCode(t *testing.T) {
 	require.Equal(t, expectedExitStatus, status.ExitStatus())
 }
 
+func TestReloadRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	limiter := newReloadRateLimiter(time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	// Three rapid requests: only the first executes, the rest coalesce.
+	require.True(t, limiter.request())
+	require.False(t, limiter.request())
+	require.False(t, limiter.request())
+	require.False(t, limiter.takePending())
+
+	// Once the interval has passed, the coalesced requests collapse into a
+	// single pending reload.
+	now = now.Add(time.Minute)
+	require.True(t, limiter.takePending())
+	require.False(t, limiter.takePending())
+
+	// A zero interval never limits.
+	unlimited := newReloadRateLimiter(0)
+	require.True(t, unlimited.request())
+	require.True(t, unlimited.request())
+	require.False(t, unlimited.takePending())
+}
+
+func TestParseResponseHeaders(t *testing.T) {
+	t.Parallel()
+
+	headers, err := parseResponseHeaders([]string{
+		"Strict-Transport-Security: max-age=63072000",
+		"content-security-policy:default-src 'self'",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"Strict-Transport-Security": "max-age=63072000",
+		"Content-Security-Policy":   "default-src 'self'",
+	}, headers)
+
+	_, err = parseResponseHeaders([]string{"not-a-header"})
+	require.Error(t, err)
+
+	_, err = parseResponseHeaders([]string{": empty-name"})
+	require.Error(t, err)
+
+	_, err = parseResponseHeaders([]string{"Connection: close"})
+	require.Error(t, err)
+}
+
+func TestConfigCheckMode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name       string
+		configFile string
+		exitStatus int
+	}{
+		{name: "valid config", configFile: promConfig, exitStatus: 0},
+		{name: "missing config", configFile: "fake-input-file", exitStatus: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prom := exec.Command(promPath, "-test.main", "--config.check", "--config.file="+tc.configFile)
+			err := prom.Run()
+			if tc.exitStatus == 0 {
+				require.NoError(t, err)
+				return
+			}
+			var exitError *exec.ExitError
+			require.ErrorAs(t, err, &exitError)
+			status := exitError.Sys().(syscall.WaitStatus)
+			require.Equal(t, tc.exitStatus, status.ExitStatus())
+		})
+	}
+}
+
 type senderFunc func(alerts ...*notifier.Alert)
 
 func (s senderFunc) Send(alerts ...*notifier.Alert) {
@@ -208,6 +316,49 @@ func TestSendAlerts(t *testing.T) {
 	}
 }
 
+func TestSendAlertsRelabel(t *testing.T) {
+	dropCfg := relabel.DefaultRelabelConfig
+	dropCfg.SourceLabels = model.LabelNames{"severity"}
+	dropCfg.Regex = relabel.MustNewRegexp("debug")
+	dropCfg.Action = relabel.Drop
+
+	renameCfg := relabel.DefaultRelabelConfig
+	renameCfg.SourceLabels = model.LabelNames{"env"}
+	renameCfg.TargetLabel = "environment"
+	renameCfg.Action = relabel.Replace
+
+	dropOldCfg := relabel.DefaultRelabelConfig
+	dropOldCfg.Regex = relabel.MustNewRegexp("env")
+	dropOldCfg.Action = relabel.LabelDrop
+
+	in := []*rules.Alert{
+		{
+			Labels:     labels.FromStrings("alertname", "noisy", "severity", "debug"),
+			FiredAt:    time.Unix(2, 0),
+			ValidUntil: time.Unix(3, 0),
+		}, {
+			Labels:     labels.FromStrings("alertname", "kept", "env", "prod"),
+			FiredAt:    time.Unix(2, 0),
+			ValidUntil: time.Unix(3, 0),
+		},
+	}
+
+	var got []*notifier.Alert
+	sender := senderFunc(func(alerts ...*notifier.Alert) {
+		got = append(got, alerts...)
+	})
+	rules.SendAlerts(sender, "http://localhost:9090", &dropCfg, &renameCfg, &dropOldCfg)(context.TODO(), "up", in...)
+
+	// The debug alert is dropped, the other has env renamed to environment.
+	require.Len(t, got, 1)
+	require.Equal(t, labels.FromStrings("alertname", "kept", "environment", "prod"), got[0].Labels)
+
+	// If every alert is dropped the sender is not invoked at all.
+	got = nil
+	rules.SendAlerts(sender, "http://localhost:9090", &dropCfg)(context.TODO(), "up", in[:1]...)
+	require.Empty(t, got)
+}
+
 func TestWALSegmentSizeBounds(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
@@ -334,6 +485,54 @@ func TestMaxBlockChunkSegmentSizeBounds(t *testing.T) {
 	}
 }
 
+func TestReloadConfigDumpFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "prometheus.yml")
+	dumpPath := filepath.Join(tmpDir, "resolved.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte("scrape_configs:\n  - job_name: dump-test\n"), 0o644))
+
+	err := reloadConfig(configPath, dumpPath, false, promslog.NewNopLogger(), &safePromQLNoStepSubqueryInterval{}, func(bool) {})
+	require.NoError(t, err)
+
+	dumped, err := os.ReadFile(dumpPath)
+	require.NoError(t, err)
+	require.Contains(t, string(dumped), "job_name: dump-test")
+	// The dump contains the resolved defaults, not just the source file.
+	require.Contains(t, string(dumped), "scrape_interval: 1m")
+}
+
+func TestReloadConfigValidationPhase(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "prometheus.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte("scrape_configs:\n  - job_name: validation-test\n"), 0o644))
+
+	applied := make(map[string]bool)
+	rls := []reloader{
+		{
+			name:     "first",
+			reloader: func(*config.Config) error { applied["first"] = true; return nil },
+		}, {
+			name:      "second",
+			validator: func(*config.Config) error { return nil },
+			reloader:  func(*config.Config) error { applied["second"] = true; return nil },
+		}, {
+			name:      "third",
+			validator: func(*config.Config) error { return errors.New("nope") },
+			reloader:  func(*config.Config) error { applied["third"] = true; return nil },
+		},
+	}
+
+	// A failing validator rejects the reload before any reloader is applied.
+	err := reloadConfig(configPath, "", false, promslog.NewNopLogger(), &safePromQLNoStepSubqueryInterval{}, func(bool) {}, rls...)
+	require.EqualError(t, err, fmt.Sprintf(`configuration rejected by "third" during validation (--config.file=%q)`, configPath))
+	require.Empty(t, applied)
+
+	// With all validators passing, every reloader is applied.
+	rls[2].validator = nil
+	require.NoError(t, reloadConfig(configPath, "", false, promslog.NewNopLogger(), &safePromQLNoStepSubqueryInterval{}, func(bool) {}, rls...))
+	require.Equal(t, map[string]bool{"first": true, "second": true, "third": true}, applied)
+}
+
 func TestTimeMetrics(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -531,6 +730,83 @@ func TestModeSpecificFlags(t *testing.T) {
 	}
 }
 
+func TestReadOnlyMode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	t.Parallel()
+
+	for _, readOnly := range []bool{true, false} {
+		t.Run(fmt.Sprintf("read-only=%t", readOnly), func(t *testing.T) {
+			t.Parallel()
+			args := []string{"-test.main", "--web.listen-address=0.0.0.0:0", "--storage.tsdb.path=" + t.TempDir(), "--config.file=" + promConfig}
+			if readOnly {
+				args = append(args, "--web.read-only")
+			}
+			prom := exec.Command(promPath, args...)
+			var stderr bytes.Buffer
+			prom.Stderr = &stderr
+			require.NoError(t, prom.Start())
+
+			time.Sleep(startupTime)
+			require.NoError(t, prom.Process.Signal(syscall.SIGTERM))
+			require.NoError(t, prom.Wait())
+
+			logs := stderr.String()
+			if readOnly {
+				require.Contains(t, logs, "Read-only mode enabled")
+				// The scrape, rule and notifier managers never started, so
+				// shutdown doesn't stop them either.
+				require.NotContains(t, logs, "Stopping scrape manager...")
+			} else {
+				require.NotContains(t, logs, "Read-only mode enabled")
+				require.Contains(t, logs, "Stopping scrape manager...")
+			}
+		})
+	}
+}
+
+func TestWriteDebugProfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	heapPath, err := writeDebugProfiles(dir, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "heap-20240102T030405Z.pprof"), heapPath)
+
+	for _, name := range []string{"heap-20240102T030405Z.pprof", "goroutine-20240102T030405Z.pprof"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Positive(t, fi.Size(), "profile %s is empty", name)
+	}
+}
+
+type blockingCloseStorage struct {
+	storage.Storage
+	unblock chan struct{}
+}
+
+func (s *blockingCloseStorage) Close() error {
+	<-s.unblock
+	return nil
+}
+
+func TestCloseWithTimeout(t *testing.T) {
+	t.Parallel()
+
+	db := &blockingCloseStorage{unblock: make(chan struct{})}
+	rs := &readyStorage{}
+	rs.Set(db, 0)
+
+	start := time.Now()
+	err := rs.CloseWithTimeout(50 * time.Millisecond)
+	require.EqualError(t, err, "storage did not close within 50ms; resources may still be releasing")
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// Once the underlying close finishes, closing again succeeds.
+	close(db.unblock)
+	require.NoError(t, rs.CloseWithTimeout(5*time.Second))
+}
+
 func TestDocumentation(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.SkipNow()
@@ -623,3 +899,37 @@ func TestRwProtoMsgFlagParser(t *testing.T) {
 		})
 	}
 }
+
+func TestTakeDBSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	db, err := tsdb.Open(dir, nil, nil, tsdb.DefaultOptions(), nil)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	// A snapshot taken through the admin API must survive retention pruning.
+	manual := filepath.Join(dir, "snapshots", "20240101T000000Z-0000000000000000")
+	require.NoError(t, os.MkdirAll(manual, 0o777))
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		name, err := takeDBSnapshot(db, 2)
+		require.NoError(t, err)
+		names = append(names, name)
+	}
+
+	// Only the retention count of periodic snapshots remains, plus the
+	// manual snapshot.
+	entries, err := os.ReadDir(filepath.Join(dir, "snapshots"))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	var periodic int
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), periodicSnapshotPrefix) {
+			require.Contains(t, names, e.Name())
+			periodic++
+		}
+	}
+	require.Equal(t, 2, periodic)
+	_, err = os.Stat(manual)
+	require.NoError(t, err)
+}
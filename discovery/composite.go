@@ -0,0 +1,103 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// NewCompositeProvider returns a Discoverer that unions the target groups of
+// all given providers into one logical source. Whenever any child sends an
+// update, the composite emits the union of the latest groups of every child.
+// Sources are namespaced with the child's index so providers using the same
+// source names don't collide, and a stalled or stopped child doesn't drop the
+// targets of the others.
+func NewCompositeProvider(providers ...Discoverer) Discoverer {
+	return &compositeProvider{providers: providers}
+}
+
+type compositeProvider struct {
+	providers []Discoverer
+}
+
+// Run implements the Discoverer interface.
+func (c *compositeProvider) Run(ctx context.Context, up chan<- []*targetgroup.Group) {
+	type update struct {
+		idx int
+		tgs []*targetgroup.Group
+	}
+	updates := make(chan update)
+
+	for i, p := range c.providers {
+		ch := make(chan []*targetgroup.Group)
+		go p.Run(ctx, ch)
+		go func(idx int) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case tgs, ok := <-ch:
+					if !ok {
+						return
+					}
+					select {
+					case updates <- update{idx: idx, tgs: tgs}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}(i)
+	}
+
+	// Latest groups per child, keyed by their namespaced source.
+	latest := make([]map[string]*targetgroup.Group, len(c.providers))
+	for i := range latest {
+		latest[i] = map[string]*targetgroup.Group{}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-updates:
+			for _, tg := range u.tgs {
+				if tg == nil {
+					continue
+				}
+				namespaced := *tg
+				namespaced.Source = fmt.Sprintf("%d/%s", u.idx, tg.Source)
+				latest[u.idx][namespaced.Source] = &namespaced
+			}
+
+			var all []*targetgroup.Group
+			for _, tgs := range latest {
+				for _, tg := range tgs {
+					all = append(all, tg)
+				}
+			}
+			sort.Slice(all, func(i, j int) bool { return all[i].Source < all[j].Source })
+
+			select {
+			case up <- all:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
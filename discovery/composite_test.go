@@ -0,0 +1,95 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// channelProvider replays the updates pushed into its channel.
+type channelProvider struct {
+	ch chan []*targetgroup.Group
+}
+
+func (p *channelProvider) Run(ctx context.Context, up chan<- []*targetgroup.Group) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tgs := <-p.ch:
+			select {
+			case up <- tgs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func TestCompositeProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	group := func(source, target string) *targetgroup.Group {
+		return &targetgroup.Group{
+			Source:  source,
+			Targets: []model.LabelSet{{model.AddressLabel: model.LabelValue(target)}},
+		}
+	}
+
+	p1 := &channelProvider{ch: make(chan []*targetgroup.Group)}
+	p2 := &channelProvider{ch: make(chan []*targetgroup.Group)}
+
+	up := make(chan []*targetgroup.Group)
+	go NewCompositeProvider(p1, p2).Run(ctx, up)
+
+	recv := func() []*targetgroup.Group {
+		select {
+		case tgs := <-up:
+			return tgs
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for composite update")
+			return nil
+		}
+	}
+
+	// First child reports a group.
+	p1.ch <- []*targetgroup.Group{group("static", "one:9090")}
+	tgs := recv()
+	require.Len(t, tgs, 1)
+	require.Equal(t, "0/static", tgs[0].Source)
+
+	// Second child uses the same source name; the union keeps both thanks
+	// to the namespacing.
+	p2.ch <- []*targetgroup.Group{group("static", "two:9090")}
+	tgs = recv()
+	require.Len(t, tgs, 2)
+	require.Equal(t, "0/static", tgs[0].Source)
+	require.Equal(t, "1/static", tgs[1].Source)
+	require.Equal(t, model.LabelValue("two:9090"), tgs[1].Targets[0][model.AddressLabel])
+
+	// An update from either child propagates while the other's targets stay.
+	p1.ch <- []*targetgroup.Group{group("static", "one:9091")}
+	tgs = recv()
+	require.Len(t, tgs, 2)
+	require.Equal(t, model.LabelValue("one:9091"), tgs[0].Targets[0][model.AddressLabel])
+	require.Equal(t, model.LabelValue("two:9090"), tgs[1].Targets[0][model.AddressLabel])
+}
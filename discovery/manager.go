@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"log/slog"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -86,14 +87,15 @@ func NewManager(ctx context.Context, logger *slog.Logger, registerer prometheus.
 		logger = promslog.NewNopLogger()
 	}
 	mgr := &Manager{
-		logger:      logger,
-		syncCh:      make(chan map[string][]*targetgroup.Group),
-		targets:     make(map[poolKey]map[string]*targetgroup.Group),
-		ctx:         ctx,
-		updatert:    5 * time.Second,
-		triggerSend: make(chan struct{}, 1),
-		registerer:  registerer,
-		sdMetrics:   sdMetrics,
+		logger:         logger,
+		syncCh:         make(chan map[string][]*targetgroup.Group),
+		targets:        make(map[poolKey]map[string]*targetgroup.Group),
+		providerErrors: make(map[poolKey]error),
+		ctx:            ctx,
+		updatert:       5 * time.Second,
+		triggerSend:    make(chan struct{}, 1),
+		registerer:     registerer,
+		sdMetrics:      sdMetrics,
 	}
 	for _, option := range options {
 		option(mgr)
@@ -167,6 +169,12 @@ type Manager struct {
 	// lastProvider counts providers registered during Manager's lifetime.
 	lastProvider uint
 
+	// providerErrors records the last failure observed per provider and
+	// target set, so discovery problems can be surfaced beyond the
+	// providers' own logs.
+	providerErrors    map[poolKey]error
+	providerErrorsMtx sync.Mutex
+
 	// A registerer for all service discovery metrics.
 	registerer prometheus.Registerer
 
@@ -195,6 +203,9 @@ func (m *Manager) Run() error {
 }
 
 // SyncCh returns a read only channel used by all the clients to receive target updates.
+// The channel is closed once the context passed to the manager is done and no
+// further updates can arrive, so consumers should range over it rather than
+// block on a receive forever.
 func (m *Manager) SyncCh() <-chan map[string][]*targetgroup.Group {
 	return m.syncCh
 }
@@ -300,10 +311,52 @@ func (m *Manager) startProvider(ctx context.Context, p *Provider) {
 
 	p.cancel = cancel
 
-	go p.d.Run(ctx, updates)
+	go func() {
+		// A panicking discoverer must not take the whole manager down;
+		// record the failure so it can be surfaced and let the other
+		// providers keep running.
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("discoverer panicked: %v", r)
+				m.logger.Error("Provider failed", "provider", p.name, "err", err)
+				p.mu.RLock()
+				for s := range p.subs {
+					m.recordProviderError(poolKey{setName: s, provider: p.name}, err)
+				}
+				p.mu.RUnlock()
+			}
+		}()
+		p.d.Run(ctx, updates)
+	}()
 	go m.updater(ctx, p, updates)
 }
 
+func (m *Manager) recordProviderError(key poolKey, err error) {
+	m.providerErrorsMtx.Lock()
+	m.providerErrors[key] = err
+	m.providerErrorsMtx.Unlock()
+}
+
+// ProviderErrorKey identifies the provider and target set a failure was
+// recorded for.
+type ProviderErrorKey struct {
+	SetName  string
+	Provider string
+}
+
+// ProviderErrors returns the last failure recorded for each provider and
+// target set, including discoverers that could not be instantiated and
+// discoverers that panicked while running.
+func (m *Manager) ProviderErrors() map[ProviderErrorKey]error {
+	m.providerErrorsMtx.Lock()
+	defer m.providerErrorsMtx.Unlock()
+	errs := make(map[ProviderErrorKey]error, len(m.providerErrors))
+	for k, v := range m.providerErrors {
+		errs[ProviderErrorKey{SetName: k.setName, Provider: k.provider}] = v
+	}
+	return errs
+}
+
 // cleaner cleans resources associated with provider.
 func (m *Manager) cleaner(p *Provider) {
 	m.targetsMtx.Lock()
@@ -351,6 +404,9 @@ func (m *Manager) updater(ctx context.Context, p *Provider, updates chan []*targ
 func (m *Manager) sender() {
 	ticker := time.NewTicker(m.updatert)
 	defer ticker.Stop()
+	// The sender is the only writer to the channel, so closing here cannot
+	// race with a send. It signals consumers that no more updates arrive.
+	defer close(m.syncCh)
 
 	for {
 		select {
@@ -424,10 +480,16 @@ func (m *Manager) allGroups() map[string][]*targetgroup.Group {
 				n[s] = 0
 			}
 			if tsets, ok := m.targets[poolKey{s, p.name}]; ok {
+				groups := make([]*targetgroup.Group, 0, len(tsets))
 				for _, tg := range tsets {
-					tSets[s] = append(tSets[s], tg)
+					groups = append(groups, tg)
 					n[s] += len(tg.Targets)
 				}
+				// The groups are stored in a map, so sort them by source
+				// to keep the published order stable across sends and not
+				// trigger spurious target reorderings in consumers.
+				sort.Slice(groups, func(i, j int) bool { return groups[i].Source < groups[j].Source })
+				tSets[s] = append(tSets[s], groups...)
 			}
 		}
 		p.mu.RUnlock()
@@ -464,6 +526,7 @@ func (m *Manager) registerProviders(cfgs Configs, setName string) int {
 		})
 		if err != nil {
 			m.logger.Error("Cannot create service discovery", "err", err, "type", typ, "config", setName)
+			m.recordProviderError(poolKey{setName: setName, provider: typ}, err)
 			failed++
 			return
 		}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -707,6 +708,181 @@ func TestTargetUpdatesOrder(t *testing.T) {
 	}
 }
 
+// TestSyncChGroupsSortedBySource ensures a provider's target groups are
+// published in a stable order regardless of the order they were discovered
+// in, so consumers don't see spurious reorderings.
+func TestSyncChGroupsSortedBySource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	_, sdMetrics := NewTestMetrics(t, reg)
+
+	discoveryManager := NewManager(ctx, promslog.NewNopLogger(), reg, sdMetrics)
+	require.NotNil(t, discoveryManager)
+	discoveryManager.updatert = 100 * time.Millisecond
+	go discoveryManager.Run()
+
+	tgs := func(sources ...string) []targetgroup.Group {
+		groups := make([]targetgroup.Group, 0, len(sources))
+		for _, s := range sources {
+			groups = append(groups, targetgroup.Group{
+				Source:  s,
+				Targets: []model.LabelSet{{"__instance__": model.LabelValue(s)}},
+			})
+		}
+		return groups
+	}
+	sources := func(groups []*targetgroup.Group) []string {
+		got := make([]string, 0, len(groups))
+		for _, g := range groups {
+			got = append(got, g.Source)
+		}
+		return got
+	}
+
+	provider := newMockDiscoveryProvider(
+		update{targetGroups: tgs("tp_c", "tp_a", "tp_b")},
+		update{interval: 300 * time.Millisecond, targetGroups: tgs("tp_d", "tp_b", "tp_a")},
+	)
+	discoveryManager.StartCustomProvider(ctx, "mock", provider)
+
+	syncedTargets := <-discoveryManager.SyncCh()
+	require.Equal(t, []string{"tp_a", "tp_b", "tp_c"}, sources(syncedTargets["mock"]))
+
+	syncedTargets = <-discoveryManager.SyncCh()
+	require.Equal(t, []string{"tp_a", "tp_b", "tp_c", "tp_d"}, sources(syncedTargets["mock"]))
+}
+
+type panickingProvider struct{}
+
+func (panickingProvider) Run(context.Context, chan<- []*targetgroup.Group) {
+	panic("boom")
+}
+
+// TestProviderErrorsRecorded ensures a panicking discoverer is recorded in
+// ProviderErrors without taking the other providers down.
+func TestProviderErrorsRecorded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	_, sdMetrics := NewTestMetrics(t, reg)
+
+	discoveryManager := NewManager(ctx, promslog.NewNopLogger(), reg, sdMetrics)
+	require.NotNil(t, discoveryManager)
+	discoveryManager.updatert = 100 * time.Millisecond
+	go discoveryManager.Run()
+
+	discoveryManager.ApplyConfig(map[string]Configs{
+		"prometheus": {staticConfig("foo:9090")},
+	})
+	discoveryManager.StartCustomProvider(ctx, "broken", panickingProvider{})
+
+	// The healthy provider still delivers its targets.
+	syncedTargets := <-discoveryManager.SyncCh()
+	require.Len(t, syncedTargets["prometheus"], 1)
+
+	require.Eventually(t, func() bool {
+		err, ok := discoveryManager.ProviderErrors()[ProviderErrorKey{SetName: "broken", Provider: "broken"}]
+		return ok && err != nil && strings.Contains(err.Error(), "boom")
+	}, 5*time.Second, 50*time.Millisecond, "provider panic was not recorded")
+}
+
+// TestSyncChClosedOnCancel ensures consumers blocked on SyncCh are released
+// once the manager's context is cancelled.
+func TestSyncChClosedOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	reg := prometheus.NewRegistry()
+	_, sdMetrics := NewTestMetrics(t, reg)
+
+	discoveryManager := NewManager(ctx, promslog.NewNopLogger(), reg, sdMetrics)
+	require.NotNil(t, discoveryManager)
+	discoveryManager.updatert = 100 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() { done <- discoveryManager.Run() }()
+
+	discoveryManager.ApplyConfig(map[string]Configs{
+		"prometheus": {staticConfig("foo:9090")},
+	})
+	<-discoveryManager.SyncCh()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "manager run did not return after cancellation")
+	}
+
+	// The channel must eventually report closure, possibly after a final
+	// in-flight update.
+	for {
+		select {
+		case _, ok := <-discoveryManager.SyncCh():
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "sync channel was not closed after cancellation")
+		}
+	}
+}
+
+// TestRapidUpdatesAreCoalesced ensures that several updates from one provider
+// arriving within a single update interval are merged and only the final
+// state is published on SyncCh.
+func TestRapidUpdatesAreCoalesced(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	_, sdMetrics := NewTestMetrics(t, reg)
+
+	discoveryManager := NewManager(ctx, promslog.NewNopLogger(), reg, sdMetrics, Updatert(500*time.Millisecond))
+	require.NotNil(t, discoveryManager)
+
+	tp := newMockDiscoveryProvider(
+		update{
+			targetGroups: []targetgroup.Group{
+				{Source: "tg1", Targets: []model.LabelSet{{"__instance__": "1"}}},
+			},
+		},
+		update{
+			interval: 10 * time.Millisecond,
+			targetGroups: []targetgroup.Group{
+				{Source: "tg1", Targets: []model.LabelSet{{"__instance__": "2"}}},
+			},
+		},
+		update{
+			interval: 10 * time.Millisecond,
+			targetGroups: []targetgroup.Group{
+				{Source: "tg1", Targets: []model.LabelSet{{"__instance__": "3"}}},
+			},
+		},
+	)
+	discoveryManager.StartCustomProvider(ctx, "mock", tp)
+	go discoveryManager.Run()
+
+	select {
+	case tgs := <-discoveryManager.SyncCh():
+		assertEqualGroups(t, tgs["mock"], []*targetgroup.Group{
+			{Source: "tg1", Targets: []model.LabelSet{{"__instance__": "3"}}},
+		})
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no update arrived within the timeout limit")
+	}
+
+	select {
+	case tgs := <-discoveryManager.SyncCh():
+		require.FailNowf(t, "unexpected update", "intermediate updates should have been coalesced, got %v", tgs)
+	case <-time.After(time.Second):
+	}
+}
+
 func assertEqualGroups(t *testing.T, got, expected []*targetgroup.Group) {
 	t.Helper()
 
@@ -1196,6 +1372,50 @@ func (s lockStaticDiscoverer) Run(ctx context.Context, up chan<- []*targetgroup.
 	}
 }
 
+func TestGaugeDiscoveredTargets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	_, sdMetrics := NewTestMetrics(t, reg)
+
+	discoveryManager := NewManager(ctx, promslog.NewNopLogger(), reg, sdMetrics)
+	require.NotNil(t, discoveryManager)
+	discoveryManager.updatert = 100 * time.Millisecond
+	go discoveryManager.Run()
+
+	c := map[string]Configs{
+		"prometheus": {
+			staticConfig("foo:9090", "bar:9090"),
+		},
+	}
+	discoveryManager.ApplyConfig(c)
+	<-discoveryManager.SyncCh()
+
+	discovered := client_testutil.ToFloat64(discoveryManager.metrics.DiscoveredTargets.WithLabelValues("prometheus"))
+	require.Equal(t, 2.0, discovered, "Expected to have 2 discovered targets.")
+
+	c["prometheus"] = Configs{
+		staticConfig("foo:9090"),
+	}
+	discoveryManager.ApplyConfig(c)
+	<-discoveryManager.SyncCh()
+
+	discovered = client_testutil.ToFloat64(discoveryManager.metrics.DiscoveredTargets.WithLabelValues("prometheus"))
+	require.Equal(t, 1.0, discovered, "Expected to have 1 discovered target.")
+
+	// Multiple providers in one job aggregate into the job's gauge.
+	c["prometheus"] = Configs{
+		staticConfig("foo:9090", "bar:9090"),
+		staticConfig("baz:9090"),
+	}
+	discoveryManager.ApplyConfig(c)
+	<-discoveryManager.SyncCh()
+
+	discovered = client_testutil.ToFloat64(discoveryManager.metrics.DiscoveredTargets.WithLabelValues("prometheus"))
+	require.Equal(t, 3.0, discovered, "Expected the gauge to equal the combined target count.")
+}
+
 func TestGaugeFailedConfigs(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
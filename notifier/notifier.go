@@ -381,7 +381,13 @@ func (n *Manager) targetUpdateLoop(tsets <-chan map[string][]*targetgroup.Group)
 			select {
 			case <-n.stopRequested:
 				return
-			case ts := <-tsets:
+			case ts, ok := <-tsets:
+				if !ok {
+					// The discovery channel was closed, no further
+					// updates will arrive. Wait for the stop request.
+					tsets = nil
+					continue
+				}
 				n.reload(ts)
 			}
 		}
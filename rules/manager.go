@@ -29,6 +29,7 @@ import (
 	"golang.org/x/sync/semaphore"
 
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
 	"github.com/prometheus/prometheus/promql"
@@ -333,11 +334,13 @@ func (m *Manager) LoadGroups(
 					))
 					continue
 				}
-				rules = append(rules, NewRecordingRule(
+				rr := NewRecordingRule(
 					r.Record.Value,
 					expr,
 					mLabels,
-				))
+				)
+				rr.SetExternalLabels(externalLabels)
+				rules = append(rules, rr)
 			}
 
 			// Check dependencies between rules and store it on the Rule itself.
@@ -414,15 +417,25 @@ type Sender interface {
 	Send(alerts ...*notifier.Alert)
 }
 
-// SendAlerts implements the rules.NotifyFunc for a Notifier.
-func SendAlerts(s Sender, externalURL string) NotifyFunc {
+// SendAlerts implements the rules.NotifyFunc for a Notifier. The optional
+// relabel configs are applied to each alert's labels before dispatch; alerts
+// dropped by relabeling are not sent.
+func SendAlerts(s Sender, externalURL string, relabelConfigs ...*relabel.Config) NotifyFunc {
 	return func(ctx context.Context, expr string, alerts ...*Alert) {
 		var res []*notifier.Alert
 
 		for _, alert := range alerts {
+			lset := alert.Labels
+			if len(relabelConfigs) > 0 {
+				var keep bool
+				lset, keep = relabel.Process(lset, relabelConfigs...)
+				if !keep {
+					continue
+				}
+			}
 			a := &notifier.Alert{
 				StartsAt:     alert.FiredAt,
-				Labels:       alert.Labels,
+				Labels:       lset,
 				Annotations:  alert.Annotations,
 				GeneratorURL: externalURL + strutil.TableLinkForExpression(expr),
 			}
@@ -434,7 +447,7 @@ func SendAlerts(s Sender, externalURL string) NotifyFunc {
 			res = append(res, a)
 		}
 
-		if len(alerts) > 0 {
+		if len(res) > 0 {
 			s.Send(res...)
 		}
 	}
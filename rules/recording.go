@@ -18,15 +18,20 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"go.uber.org/atomic"
 	"gopkg.in/yaml.v2"
 
+	"github.com/prometheus/common/model"
+
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/template"
 )
 
 // A RecordingRule records its vector expression into new timeseries.
@@ -34,6 +39,12 @@ type RecordingRule struct {
 	name   string
 	vector parser.Expr
 	labels labels.Labels
+	// Whether any label value contains template syntax and needs expansion
+	// per evaluation. Plain string values skip templating entirely.
+	templatedLabels bool
+	// External labels made available to templated label values. Set once
+	// when rules are built, before the rule is evaluated.
+	externalLabels map[string]string
 	// The health of the recording rule.
 	health *atomic.String
 	// Timestamp of last evaluation of the recording rule.
@@ -43,20 +54,32 @@ type RecordingRule struct {
 	// Duration of how long it took to evaluate the recording rule.
 	evaluationDuration *atomic.Duration
 
+	// Optional per-rule timeout applied to each evaluation. Zero means no
+	// extra timeout beyond what the context already carries.
+	evalTimeout *atomic.Duration
+
 	noDependentRules  *atomic.Bool
 	noDependencyRules *atomic.Bool
 }
 
 // NewRecordingRule returns a new recording rule.
 func NewRecordingRule(name string, vector parser.Expr, lset labels.Labels) *RecordingRule {
+	templated := false
+	lset.Range(func(l labels.Label) {
+		if strings.Contains(l.Value, "{{") {
+			templated = true
+		}
+	})
 	return &RecordingRule{
 		name:                name,
 		vector:              vector,
 		labels:              lset,
+		templatedLabels:     templated,
 		health:              atomic.NewString(string(HealthUnknown)),
 		evaluationTimestamp: atomic.NewTime(time.Time{}),
 		evaluationDuration:  atomic.NewDuration(0),
 		lastError:           atomic.NewError(nil),
+		evalTimeout:         atomic.NewDuration(0),
 		noDependentRules:    atomic.NewBool(false),
 		noDependencyRules:   atomic.NewBool(false),
 	}
@@ -80,11 +103,30 @@ func (rule *RecordingRule) Labels() labels.Labels {
 // Eval evaluates the rule and then overrides the metric names and labels accordingly.
 func (rule *RecordingRule) Eval(ctx context.Context, queryOffset time.Duration, ts time.Time, query QueryFunc, _ *url.URL, limit int) (promql.Vector, error) {
 	ctx = NewOriginContext(ctx, NewRuleDetail(rule))
+
+	timeout := rule.evalTimeout.Load()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	vector, err := query(ctx, rule.vector.String(), ts.Add(-queryOffset))
 	if err != nil {
+		if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("rule evaluation exceeded timeout of %s: %w", timeout, err)
+		}
 		return nil, err
 	}
 
+	ruleLabels := rule.labels
+	if rule.templatedLabels {
+		ruleLabels, err = rule.expandLabels(ctx, ts, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Override the metric name and labels.
 	lb := labels.NewBuilder(labels.EmptyLabels())
 
@@ -94,7 +136,7 @@ func (rule *RecordingRule) Eval(ctx context.Context, queryOffset time.Duration,
 		lb.Reset(sample.Metric)
 		lb.Set(labels.MetricName, rule.name)
 
-		rule.labels.Range(func(l labels.Label) {
+		ruleLabels.Range(func(l labels.Label) {
 			lb.Set(l.Name, l.Value)
 		})
 
@@ -117,6 +159,56 @@ func (rule *RecordingRule) Eval(ctx context.Context, queryOffset time.Duration,
 	return vector, nil
 }
 
+// expandLabels expands templated label values against the evaluation
+// timestamp and the rule's external labels. Plain values pass through as-is.
+func (rule *RecordingRule) expandLabels(ctx context.Context, ts time.Time, query QueryFunc) (labels.Labels, error) {
+	data := struct {
+		ExternalLabels map[string]string
+		EvalTime       time.Time
+	}{
+		ExternalLabels: rule.externalLabels,
+		EvalTime:       ts,
+	}
+
+	lb := labels.NewBuilder(labels.EmptyLabels())
+	var expandErr error
+	rule.labels.Range(func(l labels.Label) {
+		if expandErr != nil {
+			return
+		}
+		if !strings.Contains(l.Value, "{{") {
+			lb.Set(l.Name, l.Value)
+			return
+		}
+		tmpl := template.NewTemplateExpander(
+			ctx,
+			l.Value,
+			"__record_"+rule.name,
+			data,
+			model.Time(timestamp.FromTime(ts)),
+			template.QueryFunc(query),
+			nil,
+			nil,
+		)
+		result, err := tmpl.Expand()
+		if err != nil {
+			expandErr = fmt.Errorf("expanding label %q of rule %q: %w", l.Name, rule.name, err)
+			return
+		}
+		lb.Set(l.Name, result)
+	})
+	if expandErr != nil {
+		return labels.EmptyLabels(), expandErr
+	}
+	return lb.Labels(), nil
+}
+
+// SetExternalLabels sets the external labels available to templated label
+// values. It must be called before the rule is evaluated.
+func (rule *RecordingRule) SetExternalLabels(lset labels.Labels) {
+	rule.externalLabels = lset.Map()
+}
+
 func (rule *RecordingRule) String() string {
 	r := rulefmt.Rule{
 		Record: rule.name,
@@ -132,6 +224,16 @@ func (rule *RecordingRule) String() string {
 	return string(byt)
 }
 
+// SetEvalTimeout sets the per-rule evaluation timeout. A zero duration disables it.
+func (rule *RecordingRule) SetEvalTimeout(timeout time.Duration) {
+	rule.evalTimeout.Store(timeout)
+}
+
+// EvalTimeout returns the per-rule evaluation timeout, zero when disabled.
+func (rule *RecordingRule) EvalTimeout() time.Duration {
+	return rule.evalTimeout.Load()
+}
+
 // SetEvaluationDuration updates evaluationDuration to the time in seconds it took to evaluate the rule on its last evaluation.
 func (rule *RecordingRule) SetEvaluationDuration(dur time.Duration) {
 	rule.evaluationDuration.Store(dur)
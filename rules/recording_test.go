@@ -255,6 +255,64 @@ func TestRecordingEvalWithOrigin(t *testing.T) {
 	require.Equal(t, detail, NewRuleDetail(rule))
 }
 
+func TestRecordingRuleTemplatedLabels(t *testing.T) {
+	expr, err := parser.ParseExpr(`metric`)
+	require.NoError(t, err)
+
+	rule := NewRecordingRule("foo", expr, labels.FromStrings(
+		"plain", "value",
+		"region", `{{ .ExternalLabels.region }}`,
+		"eval_hour", `{{ .EvalTime.UTC.Hour }}`,
+	))
+	rule.SetExternalLabels(labels.FromStrings("region", "eu-1"))
+
+	evalTime := time.Date(2024, 1, 1, 13, 30, 0, 0, time.UTC)
+	result, err := rule.Eval(context.Background(), 0, evalTime, func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		return promql.Vector{{Metric: labels.FromStrings("__name__", "metric"), T: timestamp.FromTime(evalTime), F: 1}}, nil
+	}, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.Equal(t, labels.FromStrings(
+		"__name__", "foo",
+		"plain", "value",
+		"region", "eu-1",
+		"eval_hour", "13",
+	), result[0].Metric)
+
+	// Rules without templated labels take the fast path.
+	plain := NewRecordingRule("foo", expr, labels.FromStrings("plain", "value"))
+	require.False(t, plain.templatedLabels)
+}
+
+func TestRecordingRuleEvalTimeout(t *testing.T) {
+	expr, err := parser.ParseExpr(`metric`)
+	require.NoError(t, err)
+
+	rule := NewRecordingRule("foo", expr, labels.EmptyLabels())
+	rule.SetEvalTimeout(10 * time.Millisecond)
+
+	// A query that blocks until the context is done.
+	blockingQuery := func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	_, err = rule.Eval(context.Background(), 0, time.Now(), blockingQuery, nil, 0)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "rule evaluation exceeded timeout of 10ms")
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// Without a timeout the context is passed through untouched.
+	rule.SetEvalTimeout(0)
+	_, err = rule.Eval(context.Background(), 0, time.Now(), func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		_, ok := ctx.Deadline()
+		require.False(t, ok)
+		return nil, nil
+	}, nil, 0)
+	require.NoError(t, err)
+}
+
 func TestRecordingRule_SetNoDependentRules(t *testing.T) {
 	rule := NewRecordingRule("1", &parser.NumberLiteral{Val: 1}, labels.EmptyLabels())
 	require.False(t, rule.NoDependentRules())
@@ -122,7 +122,13 @@ func (m *Manager) Run(tsets <-chan map[string][]*targetgroup.Group) error {
 	go m.reloader()
 	for {
 		select {
-		case ts := <-tsets:
+		case ts, ok := <-tsets:
+			if !ok {
+				// The discovery channel was closed, no further updates
+				// will arrive. Keep running until shutdown.
+				tsets = nil
+				continue
+			}
 			m.updateTsets(ts)
 
 			select {
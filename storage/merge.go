@@ -477,6 +477,27 @@ func ChainedSeriesMerge(series ...Series) Series {
 	}
 }
 
+// StrictChainedSeriesMerge works like ChainedSeriesMerge, but when overlapping
+// series disagree about the value at the same timestamp the iterator stops and
+// reports an error through Err(). Overlapping samples with equal values are
+// merged as usual.
+func StrictChainedSeriesMerge(series ...Series) Series {
+	if len(series) == 0 {
+		return nil
+	}
+	return &SeriesEntry{
+		Lset: series[0].Labels(),
+		SampleIteratorFn: func(it chunkenc.Iterator) chunkenc.Iterator {
+			csi := getChainSampleIterator(it, len(series))
+			csi.strict = true
+			for i, s := range series {
+				csi.iterators[i] = s.Iterator(csi.iterators[i])
+			}
+			return csi
+		},
+	}
+}
+
 // chainSampleIterator is responsible to iterate over samples from different iterators of the same time series in timestamps
 // order. If one or more samples overlap, one sample from random overlapped ones is kept and all others with the same
 // timestamp are dropped. It's optimized for non-overlap cases as well.
@@ -490,6 +511,16 @@ type chainSampleIterator struct {
 	// Whether the previous and the current sample are direct neighbors
 	// within the same base iterator.
 	consecutive bool
+
+	// Set by StrictChainedSeriesMerge: overlapping samples with the same
+	// timestamp but different values cause an error instead of being
+	// silently dropped.
+	strict      bool
+	conflictErr error
+	lastType    chunkenc.ValueType
+	lastF       float64
+	lastH       *histogram.Histogram
+	lastFH      *histogram.FloatHistogram
 }
 
 // Return a chainSampleIterator initialized for length entries, re-using the memory from it if possible.
@@ -505,9 +536,50 @@ func getChainSampleIterator(it chunkenc.Iterator, length int) *chainSampleIterat
 	}
 	csi.h = nil
 	csi.lastT = math.MinInt64
+	csi.strict = false
+	csi.conflictErr = nil
 	return csi
 }
 
+// recordLast remembers the value of the sample c.curr is at, so that strict
+// mode can compare later samples with the same timestamp against it.
+func (c *chainSampleIterator) recordLast(typ chunkenc.ValueType) {
+	c.lastType = typ
+	switch typ {
+	case chunkenc.ValFloat:
+		_, c.lastF = c.curr.At()
+	case chunkenc.ValHistogram:
+		_, c.lastH = c.curr.AtHistogram(nil)
+	case chunkenc.ValFloatHistogram:
+		_, c.lastFH = c.curr.AtFloatHistogram(nil)
+	}
+}
+
+// checkConflict compares the sample c.curr is at against the last returned
+// sample, which has the same timestamp. It records an error and returns true
+// when the two disagree.
+func (c *chainSampleIterator) checkConflict(typ chunkenc.ValueType) bool {
+	equal := false
+	if typ == c.lastType {
+		switch typ {
+		case chunkenc.ValFloat:
+			_, v := c.curr.At()
+			equal = v == c.lastF || (math.IsNaN(v) && math.IsNaN(c.lastF))
+		case chunkenc.ValHistogram:
+			_, h := c.curr.AtHistogram(nil)
+			equal = h.Equals(c.lastH)
+		case chunkenc.ValFloatHistogram:
+			_, fh := c.curr.AtFloatHistogram(nil)
+			equal = fh.Equals(c.lastFH)
+		}
+	}
+	if equal {
+		return false
+	}
+	c.conflictErr = fmt.Errorf("conflicting values at timestamp %d", c.lastT)
+	return true
+}
+
 func ChainSampleIteratorFromSeries(it chunkenc.Iterator, series []Series) chunkenc.Iterator {
 	csi := getChainSampleIterator(it, len(series))
 	for i, s := range series {
@@ -531,6 +603,9 @@ func ChainSampleIteratorFromIterators(it chunkenc.Iterator, iterators []chunkenc
 }
 
 func (c *chainSampleIterator) Seek(t int64) chunkenc.ValueType {
+	if c.conflictErr != nil {
+		return chunkenc.ValNone
+	}
 	// No-op check.
 	if c.curr != nil && c.lastT >= t {
 		return c.curr.Seek(c.lastT)
@@ -552,7 +627,11 @@ func (c *chainSampleIterator) Seek(t int64) chunkenc.ValueType {
 	if len(c.h) > 0 {
 		c.curr = heap.Pop(&c.h).(chunkenc.Iterator)
 		c.lastT = c.curr.AtT()
-		return c.curr.Seek(c.lastT)
+		typ := c.curr.Seek(c.lastT)
+		if c.strict {
+			c.recordLast(typ)
+		}
+		return typ
 	}
 	c.curr = nil
 	return chunkenc.ValNone
@@ -612,6 +691,9 @@ func (c *chainSampleIterator) Next() chunkenc.ValueType {
 		currValueType   chunkenc.ValueType
 		iteratorChanged bool
 	)
+	if c.conflictErr != nil {
+		return chunkenc.ValNone
+	}
 	if c.h == nil {
 		iteratorChanged = true
 		c.h = samplesIteratorHeap{}
@@ -653,6 +735,9 @@ func (c *chainSampleIterator) Next() chunkenc.ValueType {
 			currT = c.curr.AtT()
 			if currT == c.lastT {
 				// Ignoring sample for the same timestamp.
+				if c.strict && c.checkConflict(currValueType) {
+					return chunkenc.ValNone
+				}
 				continue
 			}
 			if len(c.h) == 0 {
@@ -678,15 +763,24 @@ func (c *chainSampleIterator) Next() chunkenc.ValueType {
 		if currT != c.lastT {
 			break
 		}
+		if c.strict && c.checkConflict(currValueType) {
+			return chunkenc.ValNone
+		}
 	}
 
 	c.consecutive = !iteratorChanged
 	c.lastT = currT
+	if c.strict {
+		c.recordLast(currValueType)
+	}
 	return currValueType
 }
 
 func (c *chainSampleIterator) Err() error {
 	errs := tsdb_errors.NewMulti()
+	if c.conflictErr != nil {
+		errs.Add(c.conflictErr)
+	}
 	for _, iter := range c.iterators {
 		errs.Add(iter.Err())
 	}
@@ -1112,6 +1112,41 @@ func TestChainSampleIterator(t *testing.T) {
 	}
 }
 
+func TestStrictChainedSeriesMerge(t *testing.T) {
+	lset := labels.FromStrings("foo", "bar")
+
+	t.Run("agreeing overlap", func(t *testing.T) {
+		merged := StrictChainedSeriesMerge(
+			NewListSeries(lset, []chunks.Sample{fSample{0, 0}, fSample{1, 1}, fSample{2, 2}}),
+			NewListSeries(lset, []chunks.Sample{fSample{1, 1}, fSample{2, 2}, fSample{3, 3}}),
+		)
+		actual, err := ExpandSamples(merged.Iterator(nil), nil)
+		require.NoError(t, err)
+		require.Equal(t, []chunks.Sample{fSample{0, 0}, fSample{1, 1}, fSample{2, 2}, fSample{3, 3}}, actual)
+	})
+
+	t.Run("disagreeing overlap", func(t *testing.T) {
+		merged := StrictChainedSeriesMerge(
+			NewListSeries(lset, []chunks.Sample{fSample{0, 0}, fSample{1, 1}, fSample{2, 2}}),
+			NewListSeries(lset, []chunks.Sample{fSample{1, 1}, fSample{2, 20}}),
+		)
+		it := merged.Iterator(nil)
+		_, err := ExpandSamples(it, nil)
+		require.EqualError(t, err, "conflicting values at timestamp 2")
+		// The iterator stays exhausted once the conflict is found.
+		require.Equal(t, chunkenc.ValNone, it.Next())
+	})
+
+	t.Run("type change counts as conflict", func(t *testing.T) {
+		merged := StrictChainedSeriesMerge(
+			NewListSeries(lset, []chunks.Sample{fSample{0, 0}, fSample{1, 1}}),
+			NewListSeries(lset, []chunks.Sample{histogramSample(1, uk)}),
+		)
+		_, err := ExpandSamples(merged.Iterator(nil), nil)
+		require.EqualError(t, err, "conflicting values at timestamp 1")
+	})
+}
+
 func TestChainSampleIteratorHistogramCounterResetHint(t *testing.T) {
 	for sampleType, sampleFunc := range map[string]func(int64, histogram.CounterResetHint) chunks.Sample{
 		"histogram":       func(ts int64, hint histogram.CounterResetHint) chunks.Sample { return histogramSample(ts, hint) },
@@ -1747,6 +1747,25 @@ func TestCalculateDesiredShardsDetail(t *testing.T) {
 	}
 }
 
+func TestCalculateDesiredShardsBoundedByMaxShards(t *testing.T) {
+	_, m := newTestClientAndQueueManager(t, defaultFlushDeadline, config.RemoteWriteProtoMsgV1)
+	m.cfg.MaxShards = 2
+	samplesIn := m.dataIn
+
+	// A slow endpoint under sustained backlog: lots of data coming in, little
+	// going out, and a large delay between highest received and sent
+	// timestamps. Without the cap this would ask for dozens of shards.
+	m.numShards = m.cfg.MaxShards
+	forceEMWA(samplesIn, 100*int64(shardUpdateDuration/time.Second))
+	samplesIn.tick()
+	forceEMWA(m.dataOut, 10*int64(shardUpdateDuration/time.Second))
+	forceEMWA(m.dataDropped, 0)
+	forceEMWA(m.dataOutDuration, int64(10*float64(shardUpdateDuration)))
+	m.highestRecvTimestamp.value = 300 // Not Set() because it can only increase value.
+
+	require.Equal(t, m.cfg.MaxShards, m.calculateDesiredShards())
+}
+
 func forceEMWA(r *ewmaRate, rate int64) {
 	r.init = false
 	r.newEvents.Store(rate)
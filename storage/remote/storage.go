@@ -84,6 +84,12 @@ func (s *Storage) Notify() {
 	s.rws.Notify()
 }
 
+// SetMaxShards caps the number of shards each remote write queue may scale up
+// to. A value of 0 leaves the per-queue configuration untouched.
+func (s *Storage) SetMaxShards(n int) {
+	s.rws.SetMaxShards(n)
+}
+
 // ApplyConfig updates the state as the new config requires.
 func (s *Storage) ApplyConfig(conf *config.Config) error {
 	s.mtx.Lock()
@@ -70,6 +70,7 @@ type WriteStorage struct {
 	metadataInWAL     bool
 	samplesIn         *ewmaRate
 	flushDeadline     time.Duration
+	maxShards         int
 	interner          *pool
 	scraper           ReadyScrapeManager
 	quit              chan struct{}
@@ -125,6 +126,16 @@ func (rws *WriteStorage) run() {
 	}
 }
 
+// SetMaxShards caps the number of shards each remote write queue may scale up
+// to, overriding per-queue configurations asking for more. A value of 0 leaves
+// the per-queue configuration untouched. It must be called before ApplyConfig
+// to affect the queues built from the configuration.
+func (rws *WriteStorage) SetMaxShards(n int) {
+	rws.mtx.Lock()
+	defer rws.mtx.Unlock()
+	rws.maxShards = n
+}
+
 func (rws *WriteStorage) Notify() {
 	rws.mtx.Lock()
 	defer rws.mtx.Unlock()
@@ -195,6 +206,14 @@ func (rws *WriteStorage) ApplyConfig(conf *config.Config) error {
 			continue
 		}
 
+		queueConfig := rwConf.QueueConfig
+		if rws.maxShards > 0 && queueConfig.MaxShards > rws.maxShards {
+			queueConfig.MaxShards = rws.maxShards
+			if queueConfig.MinShards > queueConfig.MaxShards {
+				queueConfig.MinShards = queueConfig.MaxShards
+			}
+		}
+
 		// Redacted to remove any passwords in the URL (that are
 		// technically accepted but not recommended) since this is
 		// only used for metric labels.
@@ -206,7 +225,7 @@ func (rws *WriteStorage) ApplyConfig(conf *config.Config) error {
 			rws.logger,
 			rws.dir,
 			rws.samplesIn,
-			rwConf.QueueConfig,
+			queueConfig,
 			rwConf.MetadataConfig,
 			conf.GlobalConfig.ExternalLabels,
 			rwConf.WriteRelabelConfigs,
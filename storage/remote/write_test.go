@@ -207,6 +207,30 @@ func TestWriteStorageApplyConfig_Lifecycle(t *testing.T) {
 	require.NoError(t, s.Close())
 }
 
+func TestWriteStorageApplyConfig_MaxShards(t *testing.T) {
+	dir := t.TempDir()
+
+	s := NewWriteStorage(nil, nil, dir, defaultFlushDeadline, nil, false)
+	s.SetMaxShards(4)
+
+	conf := &config.Config{
+		GlobalConfig: config.DefaultGlobalConfig,
+		RemoteWriteConfigs: []*config.RemoteWriteConfig{
+			testRemoteWriteConfig(),
+		},
+	}
+	conf.RemoteWriteConfigs[0].QueueConfig.MinShards = 10
+	conf.RemoteWriteConfigs[0].QueueConfig.MaxShards = 200
+
+	hash, err := toHash(conf.RemoteWriteConfigs[0])
+	require.NoError(t, err)
+	require.NoError(t, s.ApplyConfig(conf))
+	require.Equal(t, 4, s.queues[hash].cfg.MaxShards)
+	require.Equal(t, 4, s.queues[hash].cfg.MinShards)
+
+	require.NoError(t, s.Close())
+}
+
 func TestWriteStorageApplyConfig_UpdateExternalLabels(t *testing.T) {
 	dir := t.TempDir()
 
@@ -22,6 +22,8 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/prometheus/prometheus/tsdb/chunks"
+	tsdb_errors "github.com/prometheus/prometheus/tsdb/errors"
+	"github.com/prometheus/prometheus/util/annotations"
 )
 
 type SeriesEntry struct {
@@ -169,6 +171,101 @@ func (it *listSeriesIterator) Seek(t int64) chunkenc.ValueType {
 	return it.samples.Get(it.idx).Type()
 }
 
+// NewValidatingIterator returns an iterator that passes samples from inner
+// through unchanged, but sets Err() as soon as a sample's timestamp is not
+// strictly greater than the previous one. Stale markers are not special-cased:
+// they carry ordinary timestamps and must obey the same ordering.
+func NewValidatingIterator(inner chunkenc.Iterator) chunkenc.Iterator {
+	return &validatingIterator{inner: inner, lastT: math.MinInt64}
+}
+
+type validatingIterator struct {
+	inner chunkenc.Iterator
+	lastT int64
+	err   error
+}
+
+func (it *validatingIterator) Next() chunkenc.ValueType {
+	if it.err != nil {
+		return chunkenc.ValNone
+	}
+	typ := it.inner.Next()
+	if typ == chunkenc.ValNone {
+		return chunkenc.ValNone
+	}
+	t := it.inner.AtT()
+	if t <= it.lastT {
+		it.err = fmt.Errorf("timestamp %d is not strictly greater than previous timestamp %d", t, it.lastT)
+		return chunkenc.ValNone
+	}
+	it.lastT = t
+	return typ
+}
+
+func (it *validatingIterator) Seek(t int64) chunkenc.ValueType {
+	if it.err != nil {
+		return chunkenc.ValNone
+	}
+	if it.lastT >= t {
+		return it.inner.Seek(t)
+	}
+	for {
+		typ := it.Next()
+		if typ == chunkenc.ValNone {
+			return chunkenc.ValNone
+		}
+		if it.lastT >= t {
+			return typ
+		}
+	}
+}
+
+func (it *validatingIterator) At() (int64, float64) { return it.inner.At() }
+
+func (it *validatingIterator) AtHistogram(h *histogram.Histogram) (int64, *histogram.Histogram) {
+	return it.inner.AtHistogram(h)
+}
+
+func (it *validatingIterator) AtFloatHistogram(fh *histogram.FloatHistogram) (int64, *histogram.FloatHistogram) {
+	return it.inner.AtFloatHistogram(fh)
+}
+
+func (it *validatingIterator) AtT() int64 { return it.inner.AtT() }
+
+func (it *validatingIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.inner.Err()
+}
+
+// PeekBack returns the sample n positions before the current one without
+// moving the iterator. ok is false when that position is outside the list.
+func (it *listSeriesIterator) PeekBack(n int) (t int64, v float64, ok bool) {
+	idx := it.idx - n
+	if idx < 0 || idx >= it.samples.Len() {
+		return 0, 0, false
+	}
+	s := it.samples.Get(idx)
+	return s.T(), s.F(), true
+}
+
+// SeekBefore moves the iterator to the last sample with a timestamp less than
+// or equal to t, returning ValNone when no such sample exists. Unlike Seek it
+// may move the iterator backwards.
+func (it *listSeriesIterator) SeekBefore(t int64) chunkenc.ValueType {
+	// Do binary search for the first sample past t; the wanted sample
+	// directly precedes it.
+	idx := sort.Search(it.samples.Len(), func(i int) bool {
+		return it.samples.Get(i).T() > t
+	})
+	if idx == 0 {
+		return chunkenc.ValNone
+	}
+	it.idx = idx - 1
+	return it.samples.Get(it.idx).Type()
+}
+
 func (it *listSeriesIterator) Err() error { return nil }
 
 type listSeriesIteratorWithCopy struct {
@@ -204,6 +301,44 @@ type listChunkSeriesIterator struct {
 	idx  int
 }
 
+// NewOffsetIterator returns an iterator that adds offset to the timestamp of
+// every sample of the inner iterator while preserving values, e.g. to overlay
+// last week's data on this week's without re-querying. Seek translates the
+// sought time back by offset, so the shifted samples remain seekable.
+func NewOffsetIterator(inner chunkenc.Iterator, offset int64) chunkenc.Iterator {
+	return &offsetIterator{inner: inner, offset: offset}
+}
+
+type offsetIterator struct {
+	inner  chunkenc.Iterator
+	offset int64
+}
+
+func (it *offsetIterator) At() (int64, float64) {
+	t, v := it.inner.At()
+	return t + it.offset, v
+}
+
+func (it *offsetIterator) AtHistogram(h *histogram.Histogram) (int64, *histogram.Histogram) {
+	t, ih := it.inner.AtHistogram(h)
+	return t + it.offset, ih
+}
+
+func (it *offsetIterator) AtFloatHistogram(fh *histogram.FloatHistogram) (int64, *histogram.FloatHistogram) {
+	t, ifh := it.inner.AtFloatHistogram(fh)
+	return t + it.offset, ifh
+}
+
+func (it *offsetIterator) AtT() int64 {
+	return it.inner.AtT() + it.offset
+}
+
+func (it *offsetIterator) Next() chunkenc.ValueType { return it.inner.Next() }
+
+func (it *offsetIterator) Seek(t int64) chunkenc.ValueType { return it.inner.Seek(t - it.offset) }
+
+func (it *offsetIterator) Err() error { return it.inner.Err() }
+
 // NewListChunkSeriesIterator returns listChunkSeriesIterator that allows to iterate over provided chunks.
 func NewListChunkSeriesIterator(chks ...chunks.Meta) chunks.Iterator {
 	return &listChunkSeriesIterator{chks: chks, idx: -1}
@@ -233,6 +368,231 @@ type chunkSetToSeriesSet struct {
 	sameSeriesChunks []Series
 }
 
+// NewSortedSeriesSet returns a series set that yields the series of the
+// input set sorted by their label sets. It buffers all series of the input
+// in memory before yielding the first one, so it should only be used on sets
+// of bounded size.
+func NewSortedSeriesSet(inner SeriesSet) SeriesSet {
+	return &sortedSeriesSet{inner: inner, cur: -1}
+}
+
+type sortedSeriesSet struct {
+	inner    SeriesSet
+	series   []Series
+	cur      int
+	buffered bool
+}
+
+func (s *sortedSeriesSet) Next() bool {
+	if !s.buffered {
+		s.buffered = true
+		for s.inner.Next() {
+			s.series = append(s.series, s.inner.At())
+		}
+		if s.inner.Err() != nil {
+			return false
+		}
+		sort.Slice(s.series, func(i, j int) bool {
+			return labels.Compare(s.series[i].Labels(), s.series[j].Labels()) < 0
+		})
+	}
+	s.cur++
+	return s.inner.Err() == nil && s.cur < len(s.series)
+}
+
+func (s *sortedSeriesSet) At() Series {
+	if s.cur < 0 || s.cur >= len(s.series) {
+		return nil
+	}
+	return s.series[s.cur]
+}
+
+func (s *sortedSeriesSet) Err() error                        { return s.inner.Err() }
+func (s *sortedSeriesSet) Warnings() annotations.Annotations { return s.inner.Warnings() }
+
+// NewLazySeriesSet wraps a series set so that the iterators of the series it
+// yields are only created once samples are actually requested. Consumers that
+// read nothing but labels, such as series endpoints, never trigger iterator
+// creation and the chunk decoding that may come with it.
+func NewLazySeriesSet(inner SeriesSet) SeriesSet {
+	return lazySeriesSet{inner}
+}
+
+type lazySeriesSet struct {
+	SeriesSet
+}
+
+func (s lazySeriesSet) At() Series {
+	inner := s.SeriesSet.At()
+	if inner == nil {
+		return nil
+	}
+	return &lazySeries{series: inner}
+}
+
+type lazySeries struct {
+	series Series
+}
+
+func (s *lazySeries) Labels() labels.Labels { return s.series.Labels() }
+
+func (s *lazySeries) Iterator(it chunkenc.Iterator) chunkenc.Iterator {
+	return &lazySeriesIterator{series: s.series, reuse: it}
+}
+
+// lazySeriesIterator defers creating the underlying sample iterator until the
+// first sample is requested.
+type lazySeriesIterator struct {
+	series Series
+	reuse  chunkenc.Iterator
+	it     chunkenc.Iterator
+}
+
+func (it *lazySeriesIterator) inner() chunkenc.Iterator {
+	if it.it == nil {
+		it.it = it.series.Iterator(it.reuse)
+		it.reuse = nil
+	}
+	return it.it
+}
+
+func (it *lazySeriesIterator) Next() chunkenc.ValueType        { return it.inner().Next() }
+func (it *lazySeriesIterator) Seek(t int64) chunkenc.ValueType { return it.inner().Seek(t) }
+func (it *lazySeriesIterator) At() (int64, float64)            { return it.inner().At() }
+func (it *lazySeriesIterator) AtHistogram(h *histogram.Histogram) (int64, *histogram.Histogram) {
+	return it.inner().AtHistogram(h)
+}
+
+func (it *lazySeriesIterator) AtFloatHistogram(fh *histogram.FloatHistogram) (int64, *histogram.FloatHistogram) {
+	return it.inner().AtFloatHistogram(fh)
+}
+func (it *lazySeriesIterator) AtT() int64 { return it.inner().AtT() }
+func (it *lazySeriesIterator) Err() error { return it.inner().Err() }
+
+// NewLabelCardinalityLimitedSeriesSet wraps a series set so that iteration
+// stops with an error once more than maxDistinct distinct values of the given
+// label have been seen. It guards endpoints that enumerate series against
+// unbounded label-value explosion.
+func NewLabelCardinalityLimitedSeriesSet(inner SeriesSet, maxDistinct int, label string) SeriesSet {
+	return &labelCardinalityLimitedSeriesSet{
+		inner:       inner,
+		maxDistinct: maxDistinct,
+		label:       label,
+		seen:        make(map[string]struct{}, maxDistinct),
+	}
+}
+
+type labelCardinalityLimitedSeriesSet struct {
+	inner       SeriesSet
+	maxDistinct int
+	label       string
+	seen        map[string]struct{}
+	err         error
+}
+
+func (s *labelCardinalityLimitedSeriesSet) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if !s.inner.Next() {
+		return false
+	}
+	v := s.inner.At().Labels().Get(s.label)
+	if _, ok := s.seen[v]; !ok {
+		if len(s.seen) >= s.maxDistinct {
+			s.err = fmt.Errorf("series set truncated: more than %d distinct values of label %q", s.maxDistinct, s.label)
+			return false
+		}
+		s.seen[v] = struct{}{}
+	}
+	return true
+}
+
+func (s *labelCardinalityLimitedSeriesSet) At() Series { return s.inner.At() }
+
+func (s *labelCardinalityLimitedSeriesSet) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.inner.Err()
+}
+
+func (s *labelCardinalityLimitedSeriesSet) Warnings() annotations.Annotations {
+	return s.inner.Warnings()
+}
+
+// NewTeeSeriesSet wraps a series set so that onSeries is invoked with the
+// labels of every series as it is yielded, passing the set through otherwise.
+// It enables per-query series accounting without touching the engine. The
+// callback runs synchronously on the iterating goroutine, so it must return
+// promptly and must not block.
+func NewTeeSeriesSet(inner SeriesSet, onSeries func(labels.Labels)) SeriesSet {
+	return &teeSeriesSet{inner: inner, onSeries: onSeries}
+}
+
+type teeSeriesSet struct {
+	inner    SeriesSet
+	onSeries func(labels.Labels)
+}
+
+func (s *teeSeriesSet) Next() bool {
+	if !s.inner.Next() {
+		return false
+	}
+	s.onSeries(s.inner.At().Labels())
+	return true
+}
+
+func (s *teeSeriesSet) At() Series                        { return s.inner.At() }
+func (s *teeSeriesSet) Err() error                        { return s.inner.Err() }
+func (s *teeSeriesSet) Warnings() annotations.Annotations { return s.inner.Warnings() }
+
+// NewMultiErrSeriesSet returns a series set that concatenates the series of
+// all provided sets and aggregates every error encountered along the way, so
+// data from healthy sets still flows through while no partial failure is
+// lost. The combined error is exposed through Err.
+func NewMultiErrSeriesSet(sets ...SeriesSet) SeriesSet {
+	return &multiErrSeriesSet{sets: sets}
+}
+
+type multiErrSeriesSet struct {
+	sets []SeriesSet
+	cur  int
+}
+
+func (s *multiErrSeriesSet) Next() bool {
+	for s.cur < len(s.sets) {
+		if s.sets[s.cur].Next() {
+			return true
+		}
+		s.cur++
+	}
+	return false
+}
+
+func (s *multiErrSeriesSet) At() Series {
+	if s.cur >= len(s.sets) {
+		return nil
+	}
+	return s.sets[s.cur].At()
+}
+
+func (s *multiErrSeriesSet) Err() error {
+	errs := tsdb_errors.NewMulti()
+	for _, set := range s.sets {
+		errs.Add(set.Err())
+	}
+	return errs.Err()
+}
+
+func (s *multiErrSeriesSet) Warnings() annotations.Annotations {
+	var ws annotations.Annotations
+	for _, set := range s.sets {
+		ws.Merge(set.Warnings())
+	}
+	return ws
+}
+
 // NewSeriesSetFromChunkSeriesSet converts ChunkSeriesSet to SeriesSet by decoding chunks one by one.
 func NewSeriesSetFromChunkSeriesSet(chk ChunkSeriesSet) SeriesSet {
 	return &chunkSetToSeriesSet{ChunkSeriesSet: chk}
@@ -308,13 +668,45 @@ func (c *seriesSetToChunkSet) Err() error {
 
 type seriesToChunkEncoder struct {
 	Series
+
+	maxSamplesPerChunk int
 }
 
 const seriesToChunkEncoderSplit = 120
 
 // NewSeriesToChunkEncoder encodes samples to chunks with 120 samples limit.
 func NewSeriesToChunkEncoder(series Series) ChunkSeries {
-	return &seriesToChunkEncoder{series}
+	return NewSeriesToChunkEncoderSize(series, seriesToChunkEncoderSplit)
+}
+
+// NewSeriesToChunkEncoderSize encodes samples to chunks with the given limit of
+// samples per chunk. Values below 1 fall back to the default of 120.
+func NewSeriesToChunkEncoderSize(series Series, maxSamplesPerChunk int) ChunkSeries {
+	if maxSamplesPerChunk <= 0 {
+		maxSamplesPerChunk = seriesToChunkEncoderSplit
+	}
+	return &seriesToChunkEncoder{Series: series, maxSamplesPerChunk: maxSamplesPerChunk}
+}
+
+// SampleAndChunkCount walks the underlying series once and returns the total
+// number of samples along with the chunk count implied by the encoder's split
+// size, without allocating chunk metas or encoding any chunks. Histogram
+// counter resets, which cut additional chunks during actual encoding, are not
+// accounted for.
+func (s *seriesToChunkEncoder) SampleAndChunkCount() (samples, chunkCount int, err error) {
+	it := s.Series.Iterator(nil)
+	lastType := chunkenc.ValNone
+	i := 0
+	for typ := it.Next(); typ != chunkenc.ValNone; typ = it.Next() {
+		if typ != lastType || i >= s.maxSamplesPerChunk {
+			chunkCount++
+			i = 0
+		}
+		lastType = typ
+		samples++
+		i++
+	}
+	return samples, chunkCount, it.Err()
 }
 
 func (s *seriesToChunkEncoder) Iterator(it chunks.Iterator) chunks.Iterator {
@@ -337,7 +729,7 @@ func (s *seriesToChunkEncoder) Iterator(it chunks.Iterator) chunks.Iterator {
 	seriesIter := s.Series.Iterator(nil)
 	lastType := chunkenc.ValNone
 	for typ := seriesIter.Next(); typ != chunkenc.ValNone; typ = seriesIter.Next() {
-		if typ != lastType || i >= seriesToChunkEncoderSplit {
+		if typ != lastType || i >= s.maxSamplesPerChunk {
 			// Create a new chunk if the sample type changed or too many samples in the current one.
 			chks = appendChunk(chks, mint, maxt, chk)
 			chk, err = chunkenc.NewEmptyChunk(typ.ChunkEncoding())
@@ -475,6 +867,40 @@ func ExpandSamples(iter chunkenc.Iterator, newSampleFn func(t int64, f float64,
 	}
 }
 
+// ChunkSeriesMinMax returns the time range covered by the series using only
+// the chunk metadata, without decoding any samples. For a series without
+// chunks it returns math.MaxInt64 and math.MinInt64.
+func ChunkSeriesMinMax(cs ChunkSeries) (mint, maxt int64, err error) {
+	mint, maxt = math.MaxInt64, math.MinInt64
+	it := cs.Iterator(nil)
+	for it.Next() {
+		meta := it.At()
+		if meta.MinTime < mint {
+			mint = meta.MinTime
+		}
+		if meta.MaxTime > maxt {
+			maxt = meta.MaxTime
+		}
+	}
+	return mint, maxt, it.Err()
+}
+
+// SeriesSetToMap drains the series set into a map keyed by labels.String()
+// with fully expanded samples, surfacing any iteration error. It is intended
+// for test assertions where the order of series does not matter.
+func SeriesSetToMap(ss SeriesSet) (map[string][]chunks.Sample, error) {
+	result := map[string][]chunks.Sample{}
+	for ss.Next() {
+		series := ss.At()
+		samples, err := ExpandSamples(series.Iterator(nil), nil)
+		if err != nil {
+			return nil, err
+		}
+		result[series.Labels().String()] = samples
+	}
+	return result, ss.Err()
+}
+
 // ExpandChunks iterates over all chunks in the iterator, buffering all in slice.
 func ExpandChunks(iter chunks.Iterator) ([]chunks.Meta, error) {
 	var result []chunks.Meta
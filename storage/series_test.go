@@ -14,6 +14,8 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"testing"
 
@@ -71,6 +73,136 @@ func TestListSeriesIterator(t *testing.T) {
 	require.Equal(t, chunkenc.ValNone, it.Seek(2))
 }
 
+func TestListSeriesIteratorPeekBack(t *testing.T) {
+	list := samples{
+		fSample{0, 0},
+		fSample{1, 1},
+		fSample{2, 2},
+		fSample{3, 3},
+	}
+	it := NewListSeriesIterator(list).(*listSeriesIterator)
+
+	// Before the first Next there is nothing to peek at.
+	_, _, ok := it.PeekBack(0)
+	require.False(t, ok)
+
+	// Advance to the last sample and check all offsets against the list.
+	for i := 0; i < len(list); i++ {
+		require.Equal(t, chunkenc.ValFloat, it.Next())
+	}
+	for n := 0; n < len(list); n++ {
+		ts, v, ok := it.PeekBack(n)
+		require.True(t, ok)
+		require.Equal(t, list[len(list)-1-n].T(), ts)
+		require.Equal(t, list[len(list)-1-n].F(), v)
+	}
+	// One before the first sample.
+	_, _, ok = it.PeekBack(len(list))
+	require.False(t, ok)
+
+	// PeekBack does not move the iterator.
+	ts, v := it.At()
+	require.Equal(t, int64(3), ts)
+	require.Equal(t, 3., v)
+
+	// Empty iterator.
+	it = NewListSeriesIterator(samples{}).(*listSeriesIterator)
+	_, _, ok = it.PeekBack(0)
+	require.False(t, ok)
+}
+
+func TestListSeriesIteratorSeekBefore(t *testing.T) {
+	list := samples{
+		fSample{0, 0},
+		fSample{2, 2},
+		fSample{4, 4},
+		fSample{6, 6},
+	}
+	it := NewListSeriesIterator(list).(*listSeriesIterator)
+
+	// Nothing at or before t=-1.
+	require.Equal(t, chunkenc.ValNone, it.SeekBefore(-1))
+
+	// Check every target timestamp against a brute-force scan.
+	for target := int64(0); target <= 8; target++ {
+		want := chunkenc.ValNone
+		var wantT int64
+		for _, s := range list {
+			if s.T() <= target {
+				want, wantT = chunkenc.ValFloat, s.T()
+			}
+		}
+		require.Equal(t, want, it.SeekBefore(target), "target=%d", target)
+		if want != chunkenc.ValNone {
+			ts, _ := it.At()
+			require.Equal(t, wantT, ts, "target=%d", target)
+		}
+	}
+
+	// Unlike Seek, SeekBefore may move the iterator backwards.
+	require.Equal(t, chunkenc.ValFloat, it.SeekBefore(6))
+	require.Equal(t, chunkenc.ValFloat, it.SeekBefore(0))
+	ts, v := it.At()
+	require.Equal(t, int64(0), ts)
+	require.Equal(t, 0., v)
+
+	// Empty iterator.
+	it = NewListSeriesIterator(samples{}).(*listSeriesIterator)
+	require.Equal(t, chunkenc.ValNone, it.SeekBefore(0))
+}
+
+func TestValidatingIterator(t *testing.T) {
+	t.Run("monotonic", func(t *testing.T) {
+		it := NewValidatingIterator(NewListSeriesIterator(samples{
+			fSample{0, 0},
+			fSample{1, 1},
+			fSample{2, 2},
+		}))
+		var got []fSample
+		for it.Next() == chunkenc.ValFloat {
+			ts, v := it.At()
+			got = append(got, fSample{ts, v})
+		}
+		require.NoError(t, it.Err())
+		require.Equal(t, []fSample{{0, 0}, {1, 1}, {2, 2}}, got)
+	})
+
+	t.Run("duplicate timestamp", func(t *testing.T) {
+		it := NewValidatingIterator(NewListSeriesIterator(samples{
+			fSample{0, 0},
+			fSample{1, 1},
+			fSample{1, 1.5},
+		}))
+		require.Equal(t, chunkenc.ValFloat, it.Next())
+		require.Equal(t, chunkenc.ValFloat, it.Next())
+		require.Equal(t, chunkenc.ValNone, it.Next())
+		require.EqualError(t, it.Err(), "timestamp 1 is not strictly greater than previous timestamp 1")
+	})
+
+	t.Run("decreasing timestamp", func(t *testing.T) {
+		it := NewValidatingIterator(NewListSeriesIterator(samples{
+			fSample{2, 2},
+			fSample{1, 1},
+		}))
+		require.Equal(t, chunkenc.ValFloat, it.Next())
+		require.Equal(t, chunkenc.ValNone, it.Next())
+		require.EqualError(t, it.Err(), "timestamp 1 is not strictly greater than previous timestamp 2")
+		// The iterator stays exhausted once an error is set.
+		require.Equal(t, chunkenc.ValNone, it.Next())
+	})
+
+	t.Run("seek validates skipped samples", func(t *testing.T) {
+		it := NewValidatingIterator(NewListSeriesIterator(samples{
+			fSample{0, 0},
+			fSample{2, 2},
+			fSample{1, 1},
+			fSample{3, 3},
+		}))
+		require.Equal(t, chunkenc.ValNone, it.Seek(3))
+		require.EqualError(t, it.Err(), "timestamp 1 is not strictly greater than previous timestamp 2")
+	})
+}
+
 // TestChunkSeriesSetToSeriesSet test the property of SeriesSet that says
 // returned series should be iterable even after Next is called.
 func TestChunkSeriesSetToSeriesSet(t *testing.T) {
@@ -644,6 +776,56 @@ func testHistogramsSeriesToChunks(t *testing.T, test histogramTest) {
 	}
 }
 
+func TestNewSeriesToChunkEncoderSize(t *testing.T) {
+	lbs := labels.FromStrings("__name__", "up")
+	samples := make([]chunks.Sample, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		samples = append(samples, fSample{t: int64(i), f: float64(i)})
+	}
+
+	encoder := NewSeriesToChunkEncoderSize(NewListSeries(lbs, samples), 250)
+	require.EqualValues(t, lbs, encoder.Labels())
+
+	chks, err := ExpandChunks(encoder.Iterator(nil))
+	require.NoError(t, err)
+	require.Len(t, chks, 4)
+
+	encodedSamples := chunks.ChunkMetasToSamples(chks)
+	require.Equal(t, len(samples), len(encodedSamples))
+	for i, s := range encodedSamples {
+		require.Equal(t, int64(i), s.T())
+		require.Equal(t, float64(i), s.F())
+	}
+
+	// A non-positive limit falls back to the default split of 120.
+	encoder = NewSeriesToChunkEncoderSize(NewListSeries(lbs, samples), 0)
+	chks, err = ExpandChunks(encoder.Iterator(nil))
+	require.NoError(t, err)
+	require.Len(t, chks, 9)
+}
+
+func TestSeriesToChunkEncoderSampleAndChunkCount(t *testing.T) {
+	lbs := labels.FromStrings("__name__", "up")
+	samples := make([]chunks.Sample, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		samples = append(samples, fSample{t: int64(i), f: float64(i)})
+	}
+
+	encoder := NewSeriesToChunkEncoderSize(NewListSeries(lbs, samples), 250).(*seriesToChunkEncoder)
+
+	sampleCount, chunkCount, err := encoder.SampleAndChunkCount()
+	require.NoError(t, err)
+
+	// The reported counts match an actual encoding run.
+	chks, err := ExpandChunks(encoder.Iterator(nil))
+	require.NoError(t, err)
+	require.Len(t, chks, chunkCount)
+
+	expanded, err := ExpandSamples(NewListSeries(lbs, samples).Iterator(nil), nil)
+	require.NoError(t, err)
+	require.Len(t, expanded, sampleCount)
+}
+
 func getCounterResetHint(chunk chunks.Meta) chunkenc.CounterResetHeader {
 	switch chk := chunk.Chunk.(type) {
 	case *chunkenc.HistogramChunk:
@@ -653,3 +835,268 @@ func getCounterResetHint(chunk chunks.Meta) chunkenc.CounterResetHeader {
 	}
 	return chunkenc.UnknownCounterReset
 }
+
+func TestChunkSeriesMinMax(t *testing.T) {
+	// Metas without chunk data: decoding any sample would panic, proving the
+	// bounds come from the metadata alone.
+	series := &ChunkSeriesEntry{
+		Lset: labels.FromStrings("foo", "bar"),
+		ChunkIteratorFn: func(chunks.Iterator) chunks.Iterator {
+			return NewListChunkSeriesIterator(
+				chunks.Meta{MinTime: 100, MaxTime: 200},
+				chunks.Meta{MinTime: 200, MaxTime: 300},
+			)
+		},
+	}
+	mint, maxt, err := ChunkSeriesMinMax(series)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), mint)
+	require.Equal(t, int64(300), maxt)
+
+	// A real series spanning two chunks matches the outer chunk times.
+	listSeries := NewListChunkSeriesFromSamples(labels.FromStrings("foo", "bar"),
+		[]chunks.Sample{fSample{1, 1}, fSample{5, 5}},
+		[]chunks.Sample{fSample{7, 7}, fSample{9, 9}},
+	)
+	mint, maxt, err = ChunkSeriesMinMax(listSeries)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), mint)
+	require.Equal(t, int64(9), maxt)
+
+	// Iteration errors are surfaced.
+	chunksErr := errors.New("chunks failed")
+	errSeries := &ChunkSeriesEntry{
+		Lset:            labels.FromStrings("foo", "bar"),
+		ChunkIteratorFn: func(chunks.Iterator) chunks.Iterator { return errChunksIterator{err: chunksErr} },
+	}
+	_, _, err = ChunkSeriesMinMax(errSeries)
+	require.ErrorIs(t, err, chunksErr)
+}
+
+func TestSeriesSetToMap(t *testing.T) {
+	seriesA := NewListSeries(labels.FromStrings("foo", "bar"), []chunks.Sample{fSample{1, 1}, fSample{2, 2}})
+	seriesB := NewListSeries(labels.FromStrings("foo", "baz"), []chunks.Sample{fSample{3, 3}})
+
+	expected := map[string][]chunks.Sample{
+		seriesA.Labels().String(): {fSample{1, 1}, fSample{2, 2}},
+		seriesB.Labels().String(): {fSample{3, 3}},
+	}
+
+	// The result is independent of the order the series are yielded in.
+	got, err := SeriesSetToMap(NewMockSeriesSet(seriesA, seriesB))
+	require.NoError(t, err)
+	require.Equal(t, expected, got)
+
+	got, err = SeriesSetToMap(NewMockSeriesSet(seriesB, seriesA))
+	require.NoError(t, err)
+	require.Equal(t, expected, got)
+
+	setErr := errors.New("series set failed")
+	_, err = SeriesSetToMap(ErrSeriesSet(setErr))
+	require.ErrorIs(t, err, setErr)
+}
+
+// failAfterSeriesSet yields the series of the wrapped set and then fails
+// with the given error instead of a clean end of stream.
+type failAfterSeriesSet struct {
+	SeriesSet
+	err    error
+	failed bool
+}
+
+func (s *failAfterSeriesSet) Next() bool {
+	if s.SeriesSet.Next() {
+		return true
+	}
+	s.failed = true
+	return false
+}
+
+func (s *failAfterSeriesSet) Err() error {
+	if s.failed {
+		return s.err
+	}
+	return nil
+}
+
+func TestSortedSeriesSet(t *testing.T) {
+	series := []Series{
+		NewListSeries(labels.FromStrings("foo", "baz"), []chunks.Sample{fSample{2, 2}}),
+		NewListSeries(labels.FromStrings("foo", "bar"), []chunks.Sample{fSample{1, 1}}),
+		NewListSeries(labels.FromStrings("bar", "qux"), []chunks.Sample{fSample{3, 3}}),
+	}
+
+	set := NewSortedSeriesSet(NewMockSeriesSet(series...))
+	var got []Series
+	for set.Next() {
+		got = append(got, set.At())
+	}
+	require.NoError(t, set.Err())
+	require.Equal(t, []Series{series[2], series[1], series[0]}, got)
+
+	// An inner set failing mid-stream surfaces its error and stops iteration.
+	setErr := errors.New("series set failed")
+	set = NewSortedSeriesSet(&failAfterSeriesSet{SeriesSet: NewMockSeriesSet(series...), err: setErr})
+	require.False(t, set.Next())
+	require.ErrorIs(t, set.Err(), setErr)
+}
+
+func TestLazySeriesSet(t *testing.T) {
+	var iteratorCalls int
+	entry := &SeriesEntry{
+		Lset: labels.FromStrings("foo", "bar"),
+		SampleIteratorFn: func(chunkenc.Iterator) chunkenc.Iterator {
+			iteratorCalls++
+			return NewListSeriesIterator(samples{fSample{1, 2}})
+		},
+	}
+
+	// Reading only labels, or even obtaining the iterator without consuming
+	// it, must not invoke the sample iterator function.
+	set := NewLazySeriesSet(NewMockSeriesSet(entry))
+	for set.Next() {
+		require.Equal(t, labels.FromStrings("foo", "bar"), set.At().Labels())
+		set.At().Iterator(nil)
+	}
+	require.NoError(t, set.Err())
+	require.Equal(t, 0, iteratorCalls)
+
+	// Consuming samples builds the underlying iterator exactly once.
+	set = NewLazySeriesSet(NewMockSeriesSet(entry))
+	require.True(t, set.Next())
+	it := set.At().Iterator(nil)
+	require.Equal(t, chunkenc.ValFloat, it.Next())
+	ts, v := it.At()
+	require.Equal(t, int64(1), ts)
+	require.Equal(t, 2.0, v)
+	require.Equal(t, chunkenc.ValNone, it.Next())
+	require.NoError(t, it.Err())
+	require.Equal(t, 1, iteratorCalls)
+}
+
+func TestLabelCardinalityLimitedSeriesSet(t *testing.T) {
+	var series []Series
+	for i := 0; i < 100; i++ {
+		series = append(series, NewListSeries(
+			labels.FromStrings("__name__", "metric", "instance", fmt.Sprintf("host-%03d", i)),
+			[]chunks.Sample{fSample{1, 1}},
+		))
+	}
+
+	set := NewLabelCardinalityLimitedSeriesSet(NewMockSeriesSet(series...), 10, "instance")
+	var seen int
+	for set.Next() {
+		seen++
+	}
+	// All series of the first 10 distinct values pass, the 11th stops iteration.
+	require.Equal(t, 10, seen)
+	require.EqualError(t, set.Err(), `series set truncated: more than 10 distinct values of label "instance"`)
+
+	// Under the limit the set passes everything through without error.
+	set = NewLabelCardinalityLimitedSeriesSet(NewMockSeriesSet(series[:5]...), 10, "instance")
+	seen = 0
+	for set.Next() {
+		seen++
+	}
+	require.Equal(t, 5, seen)
+	require.NoError(t, set.Err())
+}
+
+func TestTeeSeriesSet(t *testing.T) {
+	var series []Series
+	for i := 0; i < 5; i++ {
+		series = append(series, NewListSeries(
+			labels.FromStrings("__name__", "metric", "instance", fmt.Sprintf("host-%d", i)),
+			[]chunks.Sample{fSample{1, 1}},
+		))
+	}
+
+	var seen []labels.Labels
+	set := NewTeeSeriesSet(NewMockSeriesSet(series...), func(lset labels.Labels) {
+		seen = append(seen, lset)
+	})
+
+	var got []Series
+	for set.Next() {
+		got = append(got, set.At())
+	}
+	require.NoError(t, set.Err())
+
+	// The callback fires once per series, in iteration order, and the set
+	// passes everything through unchanged.
+	require.Len(t, got, len(series))
+	require.Len(t, seen, len(series))
+	for i, s := range series {
+		require.Equal(t, s, got[i])
+		require.Equal(t, s.Labels(), seen[i])
+	}
+}
+
+func TestMultiErrSeriesSet(t *testing.T) {
+	goodSeries := []Series{
+		NewListSeries(labels.FromStrings("foo", "bar"), []chunks.Sample{fSample{1, 1}}),
+		NewListSeries(labels.FromStrings("foo", "baz"), []chunks.Sample{fSample{2, 2}}),
+	}
+	setErr := errors.New("series set failed")
+
+	set := NewMultiErrSeriesSet(
+		ErrSeriesSet(setErr),
+		NewMockSeriesSet(goodSeries...),
+	)
+
+	// Series from the healthy set still flow through.
+	var got []Series
+	for set.Next() {
+		got = append(got, set.At())
+	}
+	require.Equal(t, goodSeries, got)
+
+	// The failure of the erroring set is not lost.
+	require.ErrorIs(t, set.Err(), setErr)
+
+	// No errors when all sets succeed.
+	set = NewMultiErrSeriesSet(NewMockSeriesSet(goodSeries...), NewMockSeriesSet())
+	for set.Next() {
+	}
+	require.NoError(t, set.Err())
+}
+
+func TestOffsetIterator(t *testing.T) {
+	newInner := func() chunkenc.Iterator {
+		return NewListSeriesIterator(samples{
+			fSample{10, 1},
+			fSample{20, 2},
+			fSample{30, 3},
+		})
+	}
+
+	// Positive offset shifts samples forward.
+	it := NewOffsetIterator(newInner(), 5)
+	var ts []int64
+	var vs []float64
+	for it.Next() == chunkenc.ValFloat {
+		t, v := it.At()
+		ts = append(ts, t)
+		vs = append(vs, v)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []int64{15, 25, 35}, ts)
+	require.Equal(t, []float64{1, 2, 3}, vs)
+
+	// Seek works in the shifted time domain.
+	it = NewOffsetIterator(newInner(), 5)
+	require.Equal(t, chunkenc.ValFloat, it.Seek(25))
+	tt, v := it.At()
+	require.Equal(t, int64(25), tt)
+	require.Equal(t, 2.0, v)
+	require.Equal(t, int64(25), it.AtT())
+
+	// Negative offset shifts samples backward.
+	it = NewOffsetIterator(newInner(), -5)
+	require.Equal(t, chunkenc.ValFloat, it.Seek(15))
+	tt, v = it.At()
+	require.Equal(t, int64(15), tt)
+	require.Equal(t, 2.0, v)
+
+	require.Equal(t, chunkenc.ValNone, it.Seek(100))
+}
@@ -22,7 +22,9 @@ import (
 	"math"
 	"net"
 	"net/url"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	text_template "text/template"
 	"time"
@@ -79,6 +81,73 @@ func (q queryResultByLabelSorter) Swap(i, j int) {
 	q.results[i], q.results[j] = q.results[j], q.results[i]
 }
 
+// histogramQuantile computes the q-quantile from the classic histogram
+// buckets in a query result, using the same interpolation as the PromQL
+// histogram_quantile function. Every sample must carry an "le" label and
+// the "+Inf" bucket must be present.
+func histogramQuantile(q float64, v queryResult) (float64, error) {
+	if math.IsNaN(q) || q < 0 || q > 1 {
+		return 0, fmt.Errorf("invalid quantile %v", q)
+	}
+	type bucket struct {
+		upperBound float64
+		count      float64
+	}
+	buckets := make([]bucket, 0, len(v))
+	for _, s := range v {
+		le, ok := s.Labels["le"]
+		if !ok {
+			return 0, errors.New("sample without 'le' label passed to histogramQuantile")
+		}
+		upperBound, err := strconv.ParseFloat(le, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid 'le' label value %q: %w", le, err)
+		}
+		count, err := common_templates.ConvertToFloat(s.Value)
+		if err != nil {
+			return 0, err
+		}
+		buckets = append(buckets, bucket{upperBound, count})
+	}
+	if len(buckets) < 2 {
+		return 0, errors.New("histogramQuantile needs at least two buckets")
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].upperBound < buckets[j].upperBound })
+	if !math.IsInf(buckets[len(buckets)-1].upperBound, +1) {
+		return 0, errors.New("histogramQuantile needs a bucket with 'le' label of +Inf")
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i].count < buckets[i-1].count {
+			return 0, errors.New("histogramQuantile buckets are not monotonic")
+		}
+	}
+	observations := buckets[len(buckets)-1].count
+	if observations == 0 {
+		return math.NaN(), nil
+	}
+	rank := q * observations
+	b := sort.Search(len(buckets)-1, func(i int) bool { return buckets[i].count >= rank })
+	if b == len(buckets)-1 {
+		// The quantile falls into the +Inf bucket, return the highest
+		// finite upper bound.
+		return buckets[len(buckets)-2].upperBound, nil
+	}
+	if b == 0 && buckets[0].upperBound <= 0 {
+		return buckets[0].upperBound, nil
+	}
+	var (
+		bucketStart float64
+		bucketEnd   = buckets[b].upperBound
+		count       = buckets[b].count
+	)
+	if b > 0 {
+		bucketStart = buckets[b-1].upperBound
+		count -= buckets[b-1].count
+		rank -= buckets[b-1].count
+	}
+	return bucketStart + (bucketEnd-bucketStart)*(rank/count), nil
+}
+
 // QueryFunc executes a PromQL query at the given time.
 type QueryFunc func(context.Context, string, time.Time) (promql.Vector, error)
 
@@ -105,6 +174,50 @@ func query(ctx context.Context, q string, ts time.Time, queryFn QueryFunc) (quer
 	return result, nil
 }
 
+// RangeQueryFunc executes a PromQL range query between the given times.
+type RangeQueryFunc func(ctx context.Context, q string, start, end time.Time, step time.Duration) (promql.Matrix, error)
+
+// A version of a matrix series that's easier to use from templates.
+type rangeSeries struct {
+	Labels map[string]string
+	Points []seriesPoint
+}
+
+type seriesPoint struct {
+	Timestamp int64
+	Value     float64
+}
+
+type rangeQueryResult []*rangeSeries
+
+func queryRange(ctx context.Context, q string, ts time.Time, startSeconds, endSeconds, stepSeconds int, rangeQueryFn RangeQueryFunc) (rangeQueryResult, error) {
+	if stepSeconds <= 0 {
+		return nil, fmt.Errorf("queryRange step must be positive, got %d", stepSeconds)
+	}
+	start := ts.Add(-time.Duration(startSeconds) * time.Second)
+	end := ts.Add(-time.Duration(endSeconds) * time.Second)
+	if end.Before(start) {
+		return nil, fmt.Errorf("queryRange end offset %d must not be before start offset %d", endSeconds, startSeconds)
+	}
+	matrix, err := rangeQueryFn(ctx, q, start, end, time.Duration(stepSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(rangeQueryResult, len(matrix))
+	for n, series := range matrix {
+		s := rangeSeries{
+			Labels: series.Metric.Map(),
+			Points: make([]seriesPoint, 0, len(series.Floats)),
+		}
+		for _, p := range series.Floats {
+			s.Points = append(s.Points, seriesPoint{Timestamp: p.T, Value: p.F})
+		}
+		result[n] = &s
+	}
+	return result, nil
+}
+
 // Expander executes templates in text or HTML mode with a common set of Prometheus template functions.
 type Expander struct {
 	text    string
@@ -158,6 +271,38 @@ func NewTemplateExpander(
 				}
 				return result
 			},
+			"field": func(name string, obj interface{}, def ...interface{}) interface{} {
+				var fallback interface{}
+				if len(def) > 0 {
+					fallback = def[0]
+				}
+				v := reflect.ValueOf(obj)
+				if !v.IsValid() {
+					return fallback
+				}
+				if m := v.MethodByName(name); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() == 1 {
+					return m.Call(nil)[0].Interface()
+				}
+				for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+					if v.IsNil() {
+						return fallback
+					}
+					v = v.Elem()
+				}
+				switch v.Kind() {
+				case reflect.Struct:
+					if f := v.FieldByName(name); f.IsValid() && f.CanInterface() {
+						return f.Interface()
+					}
+				case reflect.Map:
+					if v.Type().Key().Kind() == reflect.String {
+						if mv := v.MapIndex(reflect.ValueOf(name)); mv.IsValid() {
+							return mv.Interface()
+						}
+					}
+				}
+				return fallback
+			},
 			"reReplaceAll": func(pattern, repl, text string) string {
 				re := regexp.MustCompile(pattern)
 				return re.ReplaceAllString(text, repl)
@@ -165,12 +310,13 @@ func NewTemplateExpander(
 			"safeHtml": func(text string) html_template.HTML {
 				return html_template.HTML(text)
 			},
-			"match":     regexp.MatchString,
-			"title":     strings.Title, //nolint:staticcheck // TODO(beorn7): Need to come up with a replacement using the cases package.
-			"toUpper":   strings.ToUpper,
-			"toLower":   strings.ToLower,
-			"graphLink": strutil.GraphLinkForExpression,
-			"tableLink": strutil.TableLinkForExpression,
+			"match":             regexp.MatchString,
+			"title":             strings.Title, //nolint:staticcheck // TODO(beorn7): Need to come up with a replacement using the cases package.
+			"toUpper":           strings.ToUpper,
+			"toLower":           strings.ToLower,
+			"graphLink":         strutil.GraphLinkForExpression,
+			"tableLink":         strutil.TableLinkForExpression,
+			"histogramQuantile": histogramQuantile,
 			"sortByLabel": func(label string, v queryResult) queryResult {
 				sorter := queryResultByLabelSorter{v[:], label}
 				sort.Stable(sorter)
@@ -245,6 +391,35 @@ func NewTemplateExpander(
 				}
 				return fmt.Sprintf("%.4g%s", v, prefix), nil
 			},
+			"humanizeSI": func(unit string, i interface{}) (string, error) {
+				v, err := common_templates.ConvertToFloat(i)
+				if err != nil {
+					return "", err
+				}
+				if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+					return fmt.Sprintf("%.4g%s", v, unit), nil
+				}
+				if math.Abs(v) >= 1 {
+					prefix := ""
+					for _, p := range []string{"k", "M", "G", "T", "P", "E", "Z", "Y"} {
+						if math.Abs(v) < 1000 {
+							break
+						}
+						prefix = p
+						v /= 1000
+					}
+					return fmt.Sprintf("%.4g%s%s", v, prefix, unit), nil
+				}
+				prefix := ""
+				for _, p := range []string{"m", "u", "n", "p", "f", "a", "z", "y"} {
+					if math.Abs(v) >= 1 {
+						break
+					}
+					prefix = p
+					v *= 1000
+				}
+				return fmt.Sprintf("%.4g%s%s", v, prefix, unit), nil
+			},
 			"humanizeDuration": common_templates.HumanizeDuration,
 			"humanizePercentage": func(i interface{}) (string, error) {
 				v, err := common_templates.ConvertToFloat(i)
@@ -309,6 +484,17 @@ func (te Expander) Funcs(fm text_template.FuncMap) {
 	}
 }
 
+// WithRangeQuery enables the queryRange template function, backed by the
+// given range-query callback. The function takes an expression, start and
+// end offsets in seconds before the given timestamp, and a step in seconds,
+// and returns the matrix result as a structure iterable in templates.
+func (te *Expander) WithRangeQuery(ctx context.Context, timestamp model.Time, rangeQueryFunc RangeQueryFunc) *Expander {
+	te.funcMap["queryRange"] = func(q string, startSeconds, endSeconds, stepSeconds int) (rangeQueryResult, error) {
+		return queryRange(ctx, q, timestamp.Time(), startSeconds, endSeconds, stepSeconds, rangeQueryFunc)
+	}
+	return te
+}
+
 // Expand expands a template in text (non-HTML) mode.
 func (te Expander) Expand() (result string, resultErr error) {
 	// It'd better to have no alert description than to kill the whole process
@@ -79,6 +79,56 @@ func TestTemplateExpansion(t *testing.T) {
 			output:      "1.5",
 			queryResult: promql.Vector{{T: 0, F: 1.5}},
 		},
+		{
+			// Compute a quantile from histogram buckets.
+			text: "{{ query \"h_bucket\" | histogramQuantile 0.5 }}",
+			queryResult: promql.Vector{
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "1"), T: 0, F: 25},
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "2"), T: 0, F: 75},
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "+Inf"), T: 0, F: 100},
+			},
+			output: "1.5",
+		},
+		{
+			// Quantile 0 returns the lower bound of the lowest bucket.
+			text: "{{ query \"h_bucket\" | histogramQuantile 0 }}",
+			queryResult: promql.Vector{
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "1"), T: 0, F: 25},
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "+Inf"), T: 0, F: 100},
+			},
+			output: "0",
+		},
+		{
+			// Quantile 1 returns the highest finite upper bound.
+			text: "{{ query \"h_bucket\" | histogramQuantile 1 }}",
+			queryResult: promql.Vector{
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "1"), T: 0, F: 25},
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "2"), T: 0, F: 75},
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "+Inf"), T: 0, F: 100},
+			},
+			output: "2",
+		},
+		{
+			// Missing +Inf bucket.
+			text: "{{ query \"h_bucket\" | histogramQuantile 0.95 }}",
+			queryResult: promql.Vector{
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "1"), T: 0, F: 25},
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "2"), T: 0, F: 75},
+			},
+			shouldFail: true,
+			errorMsg:   `error executing template test: template: test:1:22: executing "test" at <histogramQuantile 0.95>: error calling histogramQuantile: histogramQuantile needs a bucket with 'le' label of +Inf`,
+		},
+		{
+			// Non-monotonic buckets.
+			text: "{{ query \"h_bucket\" | histogramQuantile 0.95 }}",
+			queryResult: promql.Vector{
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "1"), T: 0, F: 75},
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "2"), T: 0, F: 25},
+				{Metric: labels.FromStrings(labels.MetricName, "h_bucket", "le", "+Inf"), T: 0, F: 100},
+			},
+			shouldFail: true,
+			errorMsg:   `error executing template test: template: test:1:22: executing "test" at <histogramQuantile 0.95>: error calling histogramQuantile: histogramQuantile buckets are not monotonic`,
+		},
 		{
 			// Get value from query.
 			text: "{{ query \"metric{instance='a'}\" | first | value }}",
@@ -274,6 +324,23 @@ func TestTemplateExpansion(t *testing.T) {
 			shouldFail:  true,
 			errorMsg:    "error executing template test: template: test:1:10: executing \"test\" at <\"missing\">: can't evaluate field banana in type template.queryResult",
 		},
+		{
+			// field with an existing field.
+			text:        "{{ field \"Value\" (query \"metric\" | first) }}",
+			queryResult: promql.Vector{{T: 0, F: 11}},
+			output:      "11",
+		},
+		{
+			// field with a missing field returns the provided default.
+			text:        "{{ field \"Banana\" (query \"metric\" | first) \"n/a\" }}",
+			queryResult: promql.Vector{{T: 0, F: 11}},
+			output:      "n/a",
+		},
+		{
+			// field with a nil object returns the default instead of panicking.
+			text:   "{{ field \"Value\" nil \"fallback\" }}",
+			output: "fallback",
+		},
 		{
 			// Regex replacement.
 			text:   "{{ reReplaceAll \"(a)b\" \"x$1\" \"ab\" }}",
@@ -339,6 +406,30 @@ func TestTemplateExpansion(t *testing.T) {
 			input:  []uint64{0, 1, 1234567, math.MaxUint64},
 			output: "0:1:1.177Mi:16Ei:",
 		},
+		{
+			// HumanizeSI - float64.
+			text:   "{{ range . }}{{ humanizeSI \"bps\" . }}:{{ end }}",
+			input:  []float64{0.0, 1.0, 1500000.0, .12},
+			output: "0bps:1bps:1.5Mbps:120mbps:",
+		},
+		{
+			// HumanizeSI - string.
+			text:   "{{ range . }}{{ humanizeSI \"B\" . }}:{{ end }}",
+			input:  []string{"0.0", "1.0", "1500000.0"},
+			output: "0B:1B:1.5MB:",
+		},
+		{
+			// HumanizeSI - NaN and Inf pass through.
+			text:   "{{ range . }}{{ humanizeSI \"B\" . }}:{{ end }}",
+			input:  []float64{math.NaN(), math.Inf(1), math.Inf(-1)},
+			output: "NaNB:+InfB:-InfB:",
+		},
+		{
+			// HumanizeSI - string with error.
+			text:       `{{ humanizeSI "bps" "one" }}`,
+			shouldFail: true,
+			errorMsg:   `error executing template test: template: test:1:3: executing "test" at <humanizeSI "bps" "one">: error calling humanizeSI: strconv.ParseFloat: parsing "one": invalid syntax`,
+		},
 		{
 			// HumanizeDuration - seconds - float64.
 			text:   "{{ range . }}{{ humanizeDuration . }}:{{ end }}",
@@ -567,6 +658,41 @@ type scenario struct {
 	errorMsg    string
 }
 
+func TestTemplateExpansionQueryRange(t *testing.T) {
+	rangeQueryFunc := func(_ context.Context, q string, start, end time.Time, step time.Duration) (promql.Matrix, error) {
+		require.Equal(t, "metric", q)
+		require.Equal(t, 120*time.Second, end.Sub(start))
+		require.Equal(t, 60*time.Second, step)
+		return promql.Matrix{
+			promql.Series{
+				Metric: labels.FromStrings(labels.MetricName, "metric", "instance", "a"),
+				Floats: []promql.FPoint{{T: 0, F: 11}, {T: 60000, F: 21}},
+			},
+			promql.Series{
+				Metric: labels.FromStrings(labels.MetricName, "metric", "instance", "b"),
+				Floats: []promql.FPoint{{T: 0, F: 12}},
+			},
+		}, nil
+	}
+
+	expander := NewTemplateExpander(
+		context.Background(),
+		`{{ range queryRange "metric" 120 0 60 }}{{ .Labels.instance }}:{{ range .Points }}{{ .Value }}@{{ .Timestamp }},{{ end }} {{ end }}`,
+		"test", nil, 0, nil, nil, nil,
+	).WithRangeQuery(context.Background(), 0, rangeQueryFunc)
+
+	result, err := expander.Expand()
+	require.NoError(t, err)
+	require.Equal(t, "a:11@0,21@60000, b:12@0, ", result)
+
+	// An invalid step surfaces as a template execution error.
+	expander = NewTemplateExpander(
+		context.Background(), `{{ queryRange "metric" 120 0 0 }}`, "test", nil, 0, nil, nil, nil,
+	).WithRangeQuery(context.Background(), 0, rangeQueryFunc)
+	_, err = expander.Expand()
+	require.ErrorContains(t, err, "queryRange step must be positive")
+}
+
 func testTemplateExpansion(t *testing.T, scenarios []scenario) {
 	extURL, err := url.Parse("http://testhost:9090/path/prefix")
 	if err != nil {
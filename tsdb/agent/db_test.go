@@ -583,6 +583,43 @@ func TestWALReplay(t *testing.T) {
 	}
 }
 
+func TestWALReplayCompactedSegments(t *testing.T) {
+	const numSeries = 8
+
+	s := createTestAgentDB(t, nil, DefaultOptions())
+
+	// Spread the series over several short segments and merge them.
+	lbls := labelsForTest(t.Name(), numSeries)
+	for _, l := range lbls {
+		lset := labels.New(l...)
+
+		app := s.Appender(context.TODO())
+		_, err := app.Append(0, lset, 100, 1)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+
+		_, err = s.wal.NextSegment()
+		require.NoError(t, err)
+	}
+	merged, err := s.wal.CompactSegments()
+	require.NoError(t, err)
+	require.Equal(t, numSeries-1, merged)
+	require.NoError(t, s.Close())
+
+	// Replay opens every segment index from the start, so the merged-away
+	// segments must not leave a hole.
+	storageDir := filepath.Dir(s.wal.Dir())
+	reg := prometheus.NewRegistry()
+	replayStorage, err := Open(s.logger, reg, nil, storageDir, s.opts)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, replayStorage.Close())
+	}()
+
+	m := gatherFamily(t, reg, "prometheus_agent_active_series")
+	require.Equal(t, float64(numSeries), m.Metric[0].Gauge.GetValue(), "agent wal replay mismatch of active series count")
+}
+
 func TestLockfile(t *testing.T) {
 	tsdbutil.TestDirLockerUsage(t, func(t *testing.T, data string, createLock bool) (*tsdbutil.DirLocker, testutil.Closer) {
 		logger := promslog.NewNopLogger()
@@ -14,6 +14,7 @@
 package chunkenc
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/prometheus/prometheus/model/histogram"
@@ -273,6 +274,44 @@ func (b *bucketIterator) Next() (int, bool) {
 	return 0, false
 }
 
+// BucketBoundaries returns the boundaries of the exponential bucket with the
+// given index at the given schema, as yielded by bucketIterator for the
+// positive buckets of a histogram. The bucket covers the range (lower, upper].
+// For the negative buckets, negate and swap the returned boundaries. The upper
+// boundary of the last bucket before the ±Inf overflow bucket is capped at
+// math.MaxFloat64, mirroring the convention in the model/histogram package.
+//
+// Note that the zero bucket of a native histogram is not addressed by a bucket
+// index; its boundaries are (-ZeroThreshold, ZeroThreshold) of the individual
+// histogram, independent of schema.
+func BucketBoundaries(schema, idx int32) (lower, upper float64) {
+	return exponentialBound(schema, idx-1), exponentialBound(schema, idx)
+}
+
+// exponentialBound returns the upper boundary of the exponential bucket with
+// the given index at the given schema.
+func exponentialBound(schema, idx int32) float64 {
+	if schema < 0 {
+		exp := int(idx) << -schema
+		if exp == 1024 {
+			// This is the last bucket before the overflow bucket for
+			// ±Inf observations, see BucketBoundaries.
+			return math.MaxFloat64
+		}
+		return math.Ldexp(1, exp)
+	}
+
+	fracIdx := idx & ((1 << schema) - 1)
+	frac := math.Exp2(float64(fracIdx)/float64(int(1)<<schema) - 1)
+	exp := (int(idx) >> schema) + 1
+	if frac == 0.5 && exp == 1025 {
+		// This is the last bucket before the overflow bucket for ±Inf
+		// observations, see BucketBoundaries.
+		return math.MaxFloat64
+	}
+	return math.Ldexp(frac, exp)
+}
+
 // An Insert describes how many new buckets have to be inserted before
 // processing the pos'th bucket from the original slice.
 type Insert struct {
@@ -478,10 +517,36 @@ type bucketValue interface {
 	int64 | float64
 }
 
+// debugValidateBuckets enables validation of delta-encoded bucket counts on
+// the insert path. It is disabled by default because insert is on the hot
+// append path; flip it to true to track down silent bucket corruption.
+const debugValidateBuckets = false
+
+// ValidateBuckets walks the given delta-encoded bucket counts, reconstructs
+// the absolute counts, and returns an error if any of them is negative, which
+// is invalid for a histogram.
+func ValidateBuckets(buckets []int64) error {
+	var count int64
+	for i, b := range buckets {
+		count += b
+		if count < 0 {
+			return fmt.Errorf("bucket %d has negative count %d", i, count)
+		}
+	}
+	return nil
+}
+
 // insert merges 'in' with the provided inserts and writes them into 'out',
 // which must already have the appropriate length. 'out' is also returned for
 // convenience.
 func insert[BV bucketValue](in, out []BV, inserts []Insert, deltas bool) []BV {
+	if debugValidateBuckets && deltas {
+		if buckets, ok := any(in).([]int64); ok {
+			if err := ValidateBuckets(buckets); err != nil {
+				panic(err)
+			}
+		}
+	}
 	var (
 		oi int // Position in out.
 		v  BV  // The last value seen.
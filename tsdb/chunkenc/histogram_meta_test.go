@@ -111,6 +111,40 @@ func TestBucketIterator(t *testing.T) {
 	}
 }
 
+func TestBucketBoundaries(t *testing.T) {
+	tests := []struct {
+		schema, idx  int32
+		lower, upper float64
+	}{
+		// Schema 0 buckets are powers of 2.
+		{schema: 0, idx: -1, lower: 0.25, upper: 0.5},
+		{schema: 0, idx: 0, lower: 0.5, upper: 1},
+		{schema: 0, idx: 1, lower: 1, upper: 2},
+		{schema: 0, idx: 10, lower: 512, upper: 1024},
+		// Negative schemas cover multiple powers of 2 per bucket.
+		{schema: -1, idx: 1, lower: 1, upper: 4},
+		{schema: -1, idx: 2, lower: 4, upper: 16},
+		{schema: -2, idx: 0, lower: 0.0625, upper: 1},
+		// Positive schemas subdivide each power of 2.
+		{schema: 1, idx: 1, lower: 1, upper: 1.414213562373095},
+		{schema: 1, idx: 3, lower: 2, upper: 2.82842712474619},
+		{schema: 2, idx: 2, lower: 1.189207115002721, upper: 1.414213562373095},
+		// The last bucket before the ±Inf overflow bucket is capped at
+		// MaxFloat64, the overflow bucket itself is not.
+		{schema: 0, idx: 1024, lower: math.Ldexp(1, 1023), upper: math.MaxFloat64},
+		{schema: 0, idx: 1025, lower: math.MaxFloat64, upper: math.Inf(1)},
+		{schema: -2, idx: 256, lower: math.Ldexp(1, 1020), upper: math.MaxFloat64},
+		{schema: 2, idx: 4096, lower: math.Ldexp(math.Exp2(-0.25), 1024), upper: math.MaxFloat64},
+		// Buckets far below the zero threshold underflow to 0.
+		{schema: 0, idx: -1100, lower: 0, upper: 0},
+	}
+	for _, test := range tests {
+		lower, upper := BucketBoundaries(test.schema, test.idx)
+		require.Equal(t, test.lower, lower, "lower boundary of idx %d at schema %d", test.idx, test.schema)
+		require.Equal(t, test.upper, upper, "upper boundary of idx %d at schema %d", test.idx, test.schema)
+	}
+}
+
 func TestCompareSpansAndInsert(t *testing.T) {
 	scenarios := []struct {
 		description           string
@@ -368,6 +402,13 @@ func TestCompareSpansAndInsert(t *testing.T) {
 	}
 }
 
+func TestValidateBuckets(t *testing.T) {
+	// Absolute counts 6, 3, 3, 2, 4, 5, 1 - all valid.
+	require.NoError(t, ValidateBuckets([]int64{6, -3, 0, -1, 2, 1, -4}))
+	// Absolute counts 2, 1, -2 - invalid at position 2.
+	require.EqualError(t, ValidateBuckets([]int64{2, -1, -3}), "bucket 2 has negative count -2")
+}
+
 func TestWriteReadHistogramChunkLayout(t *testing.T) {
 	layouts := []struct {
 		schema                       int32
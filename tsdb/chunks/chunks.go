@@ -23,6 +23,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
@@ -662,17 +663,55 @@ func (s *Reader) ChunkOrIterable(meta Meta) (chunkenc.Chunk, chunkenc.Iterable,
 		return nil, nil, fmt.Errorf("segment index %d out of range", sgmIndex)
 	}
 
-	sgmBytes := s.bs[sgmIndex]
+	chk, err := s.chunkAt(s.bs[sgmIndex], chkStart)
+	return chk, nil, err
+}
 
+// Chunks reads every chunk referenced by metas and fills out, which must be
+// at least len(metas) long, in the same order. Refs are processed sorted by
+// segment and offset so each segment is walked in a single sequential pass,
+// amortizing the per-call overhead of ChunkOrIterable for series with many
+// chunks.
+func (s *Reader) Chunks(metas []Meta, out []chunkenc.Chunk) error {
+	if len(out) < len(metas) {
+		return fmt.Errorf("output slice too small: %d chunks requested, room for %d", len(metas), len(out))
+	}
+	order := make([]int, len(metas))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return metas[order[i]].Ref < metas[order[j]].Ref })
+
+	curSgm := -1
+	var sgmBytes ByteSlice
+	for _, i := range order {
+		sgmIndex, chkStart := BlockChunkRef(metas[i].Ref).Unpack()
+		if sgmIndex != curSgm {
+			if sgmIndex >= len(s.bs) {
+				return fmt.Errorf("segment index %d out of range", sgmIndex)
+			}
+			curSgm, sgmBytes = sgmIndex, s.bs[sgmIndex]
+		}
+		chk, err := s.chunkAt(sgmBytes, chkStart)
+		if err != nil {
+			return err
+		}
+		out[i] = chk
+	}
+	return nil
+}
+
+// chunkAt decodes the chunk starting at chkStart within sgmBytes.
+func (s *Reader) chunkAt(sgmBytes ByteSlice, chkStart int) (chunkenc.Chunk, error) {
 	if chkStart+MaxChunkLengthFieldSize > sgmBytes.Len() {
-		return nil, nil, fmt.Errorf("segment doesn't include enough bytes to read the chunk size data field - required:%v, available:%v", chkStart+MaxChunkLengthFieldSize, sgmBytes.Len())
+		return nil, fmt.Errorf("segment doesn't include enough bytes to read the chunk size data field - required:%v, available:%v", chkStart+MaxChunkLengthFieldSize, sgmBytes.Len())
 	}
 	// With the minimum chunk length this should never cause us reading
 	// over the end of the slice.
 	c := sgmBytes.Range(chkStart, chkStart+MaxChunkLengthFieldSize)
 	chkDataLen, n := binary.Uvarint(c)
 	if n <= 0 {
-		return nil, nil, fmt.Errorf("reading chunk length failed with %d", n)
+		return nil, fmt.Errorf("reading chunk length failed with %d", n)
 	}
 
 	chkEncStart := chkStart + n
@@ -681,18 +720,17 @@ func (s *Reader) ChunkOrIterable(meta Meta) (chunkenc.Chunk, chunkenc.Iterable,
 	chkDataEnd := chkEnd - crc32.Size
 
 	if chkEnd > sgmBytes.Len() {
-		return nil, nil, fmt.Errorf("segment doesn't include enough bytes to read the chunk - required:%v, available:%v", chkEnd, sgmBytes.Len())
+		return nil, fmt.Errorf("segment doesn't include enough bytes to read the chunk - required:%v, available:%v", chkEnd, sgmBytes.Len())
 	}
 
 	sum := sgmBytes.Range(chkDataEnd, chkEnd)
 	if err := checkCRC32(sgmBytes.Range(chkEncStart, chkDataEnd), sum); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	chkData := sgmBytes.Range(chkDataStart, chkDataEnd)
 	chkEnc := sgmBytes.Range(chkEncStart, chkEncStart+ChunkEncodingSize)[0]
-	chk, err := s.pool.Get(chunkenc.Encoding(chkEnc), chkData)
-	return chk, nil, err
+	return s.pool.Get(chunkenc.Encoding(chkEnc), chkData)
 }
 
 func nextSequenceFile(dir string) (string, int, error) {
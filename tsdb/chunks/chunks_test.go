@@ -17,6 +17,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
 )
 
 func TestReaderWithInvalidBuffer(t *testing.T) {
@@ -26,3 +28,45 @@ func TestReaderWithInvalidBuffer(t *testing.T) {
 	_, _, err := r.ChunkOrIterable(Meta{Ref: 0})
 	require.Error(t, err)
 }
+
+func TestReaderChunks(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriterWithSegSize(dir, SegmentHeaderSize+64*1024)
+	require.NoError(t, err)
+
+	const numChunks = 10000
+	metas := make([]Meta, 0, numChunks)
+	for i := 0; i < numChunks; i++ {
+		m, err := ChunkFromSamples([]Sample{sample{t: int64(i), f: float64(i)}})
+		require.NoError(t, err)
+		metas = append(metas, m)
+	}
+	require.NoError(t, w.WriteChunks(metas...))
+	require.NoError(t, w.Close())
+
+	r, err := NewDirReader(dir, nil)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, r.Close()) }()
+
+	// Reverse the input so the batch read has to restore input order itself.
+	reversed := make([]Meta, len(metas))
+	for i, m := range metas {
+		reversed[len(metas)-1-i] = m
+	}
+
+	out := make([]chunkenc.Chunk, len(reversed))
+	require.NoError(t, r.Chunks(reversed, out))
+
+	for i, m := range reversed {
+		single, _, err := r.ChunkOrIterable(m)
+		require.NoError(t, err)
+		require.Equal(t, single.Bytes(), out[i].Bytes(), "chunk %d", i)
+	}
+
+	// An out-of-range ref in the batch surfaces as an error.
+	bad := []Meta{metas[0], {Ref: ChunkRef(NewBlockChunkRef(99, 0))}}
+	require.EqualError(t, r.Chunks(bad, make([]chunkenc.Chunk, len(bad))), "segment index 99 out of range")
+
+	// The output slice must be large enough.
+	require.EqualError(t, r.Chunks(metas[:2], make([]chunkenc.Chunk, 1)), "output slice too small: 2 chunks requested, room for 1")
+}
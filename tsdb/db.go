@@ -184,6 +184,11 @@ type Options struct {
 	// OOO Native Histogram ingestion is complete.
 	EnableOOONativeHistograms bool
 
+	// MaxSampleAge is the maximum age, in milliseconds relative to the wall
+	// clock, of samples accepted by appenders. Older samples are rejected
+	// with ErrOutOfBounds. 0 disables the check.
+	MaxSampleAge int64
+
 	// OutOfOrderTimeWindow specifies how much out of order is allowed, if any.
 	// This can change during run-time, so this value from here should only be used
 	// while initialising.
@@ -814,6 +819,9 @@ func validateOpts(opts *Options, rngs []int64) (*Options, []int64) {
 	if opts.OutOfOrderTimeWindow < 0 {
 		opts.OutOfOrderTimeWindow = 0
 	}
+	if opts.MaxSampleAge < 0 {
+		opts.MaxSampleAge = 0
+	}
 
 	if len(rngs) == 0 {
 		// Start with smallest block duration and create exponential buckets until the exceed the
@@ -964,6 +972,7 @@ func open(dir string, l *slog.Logger, r prometheus.Registerer, opts *Options, rn
 	headOpts.SeriesCallback = opts.SeriesLifecycleCallback
 	headOpts.EnableExemplarStorage = opts.EnableExemplarStorage
 	headOpts.MaxExemplars.Store(opts.MaxExemplars)
+	headOpts.MaxSampleAge = opts.MaxSampleAge
 	headOpts.EnableMemorySnapshotOnShutdown = opts.EnableMemorySnapshotOnShutdown
 	headOpts.EnableNativeHistograms.Store(opts.EnableNativeHistograms)
 	headOpts.EnableOOONativeHistograms.Store(opts.EnableOOONativeHistograms)
@@ -1954,6 +1963,20 @@ func (db *DB) Head() *Head {
 	return db.head
 }
 
+// TruncateHead truncates the head block and its WAL, dropping all in-memory
+// data before mint. It is distinct from block-level retention and is mainly
+// useful for tooling and tests that need to reclaim memory after bad
+// ingestion.
+func (db *DB) TruncateHead(mint int64) error {
+	db.cmtx.Lock()
+	defer db.cmtx.Unlock()
+
+	if err := db.head.Truncate(mint); err != nil {
+		return fmt.Errorf("head truncate: %w", err)
+	}
+	return nil
+}
+
 // Close the partition.
 func (db *DB) Close() error {
 	close(db.stopc)
@@ -638,6 +638,32 @@ func TestSkippingInvalidValuesInSameTxn(t *testing.T) {
 	}, ssMap)
 }
 
+func TestDB_TruncateHead(t *testing.T) {
+	db := openTestDB(t, nil, nil)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	ctx := context.Background()
+	app := db.Appender(ctx)
+	lbls := labels.FromStrings("foo", "bar")
+	boundary := DefaultBlockDuration
+	_, err := app.Append(0, lbls, boundary-1000, 1)
+	require.NoError(t, err)
+	_, err = app.Append(0, lbls, boundary+1000, 2)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	require.NoError(t, db.TruncateHead(boundary))
+
+	// Samples before the boundary are gone, later ones remain.
+	q, err := db.Querier(0, boundary+2000)
+	require.NoError(t, err)
+	res := query(t, q, labels.MustNewMatcher(labels.MatchEqual, "foo", "bar"))
+	require.Equal(t, map[string][]chunks.Sample{
+		lbls.String(): {sample{t: boundary + 1000, f: 2}},
+	}, res)
+	require.Equal(t, boundary, db.head.MinTime())
+}
+
 func TestDB_Snapshot(t *testing.T) {
 	db := openTestDB(t, nil, nil)
 
@@ -0,0 +1,59 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows && !plan9 && !js
+
+package fileutil
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// MadviseRandom advises the kernel that the given region of a memory-mapped
+// file will be accessed in random order, disabling readahead for it.
+func MadviseRandom(b []byte) error {
+	return madvise(b, unix.MADV_RANDOM)
+}
+
+// MadviseSequential advises the kernel that the given region of a
+// memory-mapped file will be read sequentially, enabling aggressive
+// readahead for it.
+func MadviseSequential(b []byte) error {
+	return madvise(b, unix.MADV_SEQUENTIAL)
+}
+
+func madvise(b []byte, advice int) error {
+	// madvise requires the region to start on a page boundary, so shrink it
+	// to the enclosed pages. Regions smaller than a page are a no-op.
+	b = pageAligned(b)
+	if len(b) == 0 {
+		return nil
+	}
+	return unix.Madvise(b, advice)
+}
+
+func pageAligned(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	pg := uintptr(os.Getpagesize())
+	addr := uintptr(unsafe.Pointer(&b[0]))
+	off := (pg - addr%pg) % pg
+	if uintptr(len(b)) <= off {
+		return nil
+	}
+	return b[off:]
+}
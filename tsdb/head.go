@@ -164,6 +164,11 @@ type HeadOptions struct {
 
 	SamplesPerChunk int
 
+	// MaxSampleAge is the maximum age, in milliseconds relative to the wall
+	// clock, of samples accepted by appenders. Older samples are rejected
+	// with ErrOutOfBounds. 0 disables the check.
+	MaxSampleAge int64
+
 	// StripeSize sets the number of entries in the hash map, it must be a power of 2.
 	// A larger StripeSize will allocate more memory up-front, but will increase performance when handling a large number of series.
 	// A smaller StripeSize reduces the memory allocated, but can decrease performance with large number of series.
@@ -356,6 +361,7 @@ type headMetrics struct {
 	outOfBoundSamples         *prometheus.CounterVec
 	outOfOrderSamples         *prometheus.CounterVec
 	tooOldSamples             *prometheus.CounterVec
+	duplicateSamples          *prometheus.CounterVec
 	walTruncateDuration       prometheus.Summary
 	walCorruptionsTotal       prometheus.Counter
 	dataTotalReplayDuration   prometheus.Gauge
@@ -448,6 +454,10 @@ func newHeadMetrics(h *Head, r prometheus.Registerer) *headMetrics {
 			Name: "prometheus_tsdb_too_old_samples_total",
 			Help: "Total number of out of order samples ingestion failed attempts with out of support enabled, but sample outside of time window.",
 		}, []string{"type"}),
+		duplicateSamples: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_tsdb_duplicate_samples_total",
+			Help: "Total number of sample ingestion failed attempts due to a sample at the same timestamp with a different value already existing.",
+		}, []string{"type"}),
 		headTruncateFail: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "prometheus_tsdb_head_truncations_failed_total",
 			Help: "Total number of head truncations that failed.",
@@ -521,6 +531,7 @@ func newHeadMetrics(h *Head, r prometheus.Registerer) *headMetrics {
 			m.outOfBoundSamples,
 			m.outOfOrderSamples,
 			m.tooOldSamples,
+			m.duplicateSamples,
 			m.headTruncateFail,
 			m.headTruncateTotal,
 			m.checkpointDeleteFail,
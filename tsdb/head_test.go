@@ -740,6 +740,49 @@ func TestHead_ReadWAL(t *testing.T) {
 	}
 }
 
+func TestHead_ReadWAL_CompactedSegments(t *testing.T) {
+	// Segments need to be several pages big so that one-page segments count
+	// as under-utilized for CompactSegments.
+	dir := t.TempDir()
+	w, err := wlog.NewSize(nil, nil, filepath.Join(dir, "wal"), 8*32*1024, wlog.CompressionNone)
+	require.NoError(t, err)
+
+	opts := newTestHeadDefaultOptions(1000, false)
+	opts.ChunkDirRoot = dir
+	head, err := NewHead(nil, nil, w, nil, opts, nil)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, head.Close())
+	}()
+
+	// Spread series and samples over several short segments and merge them.
+	for i := 1; i <= 4; i++ {
+		populateTestWL(t, w, []interface{}{
+			[]record.RefSeries{
+				{Ref: chunks.HeadSeriesRef(i), Labels: labels.FromStrings("a", strconv.Itoa(i))},
+			},
+			[]record.RefSample{
+				{Ref: chunks.HeadSeriesRef(i), T: 100, V: float64(i)},
+			},
+		})
+		_, err := w.NextSegment()
+		require.NoError(t, err)
+	}
+	merged, err := w.CompactSegments()
+	require.NoError(t, err)
+	require.Equal(t, 3, merged)
+
+	// Replay opens every segment index from the start, so the merged-away
+	// segments must not leave a hole.
+	require.NoError(t, head.Init(math.MinInt64))
+
+	for i := 1; i <= 4; i++ {
+		s := head.series.getByID(chunks.HeadSeriesRef(i))
+		require.NotNil(t, s)
+		testutil.RequireEqual(t, labels.FromStrings("a", strconv.Itoa(i)), s.lset)
+	}
+}
+
 func TestHead_WALMultiRef(t *testing.T) {
 	head, w := newTestHead(t, 1000, wlog.CompressionNone, false)
 
@@ -6280,6 +6323,31 @@ func TestHeadAppender_AppendFloatWithSameTimestampAsPreviousHistogram(t *testing
 	require.ErrorIs(t, err, storage.NewDuplicateHistogramToFloatErr(2_000, 10.0))
 }
 
+func TestHeadAppender_MaxSampleAge(t *testing.T) {
+	opts := newTestHeadDefaultOptions(DefaultBlockDuration, false)
+	opts.MaxSampleAge = 30 * time.Minute.Milliseconds()
+	head, _ := newTestHeadWithOptions(t, wlog.CompressionNone, opts)
+	t.Cleanup(func() { head.Close() })
+
+	ls := labels.FromStrings(labels.MetricName, "test")
+	now := time.Now().UnixMilli()
+
+	{
+		// A recent sample is accepted.
+		app := head.Appender(context.Background())
+		_, err := app.Append(0, ls, now, 1.0)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+	}
+
+	// A sample older than the maximum age is rejected as out of bounds, even
+	// though it is still within the appendable window of the head.
+	app := head.Appender(context.Background())
+	_, err := app.Append(0, ls, now-45*time.Minute.Milliseconds(), 1.0)
+	require.ErrorIs(t, err, storage.ErrOutOfBounds)
+	require.NoError(t, app.Rollback())
+}
+
 func TestHeadAppender_AppendCT(t *testing.T) {
 	testHistogram := tsdbutil.GenerateTestHistogram(1)
 	testFloatHistogram := tsdbutil.GenerateTestFloatHistogram(1)
@@ -6581,3 +6649,35 @@ func testHeadAppendHistogramAndCommitConcurrency(t *testing.T, appendFn func(sto
 
 	wg.Wait()
 }
+
+func TestHeadAppender_DuplicateSamplesMetric(t *testing.T) {
+	head, _ := newTestHead(t, DefaultBlockDuration, wlog.CompressionNone, false)
+	defer func() {
+		require.NoError(t, head.Close())
+	}()
+	require.NoError(t, head.Init(0))
+
+	lbls := labels.FromStrings("a", "b")
+
+	app := head.Appender(context.Background())
+	_, err := app.Append(0, lbls, 100, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	require.Equal(t, 0.0, prom_testutil.ToFloat64(head.metrics.duplicateSamples.WithLabelValues(sampleMetricTypeFloat)))
+
+	// A different value at the same timestamp is rejected and counted.
+	app = head.Appender(context.Background())
+	_, err = app.Append(0, lbls, 100, 2)
+	require.ErrorIs(t, err, storage.ErrDuplicateSampleForTimestamp)
+	require.NoError(t, app.Rollback())
+	require.Equal(t, 1.0, prom_testutil.ToFloat64(head.metrics.duplicateSamples.WithLabelValues(sampleMetricTypeFloat)))
+
+	// Out-of-order samples are still counted separately.
+	app = head.Appender(context.Background())
+	_, err = app.Append(0, lbls, 50, 1)
+	require.ErrorIs(t, err, storage.ErrOutOfOrderSample)
+	require.NoError(t, app.Rollback())
+	require.Equal(t, 1.0, prom_testutil.ToFloat64(head.metrics.duplicateSamples.WithLabelValues(sampleMetricTypeFloat)))
+	require.Equal(t, 1.0, prom_testutil.ToFloat64(head.metrics.outOfOrderSamples.WithLabelValues(sampleMetricTypeFloat)))
+}
@@ -27,8 +27,11 @@ import (
 	"path/filepath"
 	"slices"
 	"sort"
+	"strings"
 	"unsafe"
 
+	"github.com/cespare/xxhash/v2"
+
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb/chunks"
@@ -143,6 +146,8 @@ type Writer struct {
 	buf2 encoding.Encbuf
 
 	numSymbols  int
+	numSeries   int
+	symbolBytes int64
 	symbols     *Symbols
 	symbolFile  *fileutil.MmapFile
 	lastSymbol  string
@@ -540,7 +545,37 @@ func (w *Writer) AddSeries(ref storage.SeriesRef, lset labels.Labels, chunks ...
 	w.lastSeries.CopyFrom(lset)
 	w.lastSeriesRef = ref
 	w.lastChunkRef = lastChunkRef
+	w.numSeries++
+
+	return nil
+}
+
+// SeriesWithChunks pairs a series reference and its label set with the chunk
+// metas to index, for use with AddSeriesBatch.
+type SeriesWithChunks struct {
+	Ref    storage.SeriesRef
+	Labels labels.Labels
+	Chunks []chunks.Meta
+}
 
+// AddSeriesBatch is a validated convenience wrapper around AddSeries: batches
+// containing duplicate references are rejected before anything is written,
+// then the series are added one by one. It does not batch the writes
+// themselves. As with AddSeries, series must be added in order of their label
+// sets and references.
+func (w *Writer) AddSeriesBatch(series []SeriesWithChunks) error {
+	refs := make(map[storage.SeriesRef]struct{}, len(series))
+	for _, s := range series {
+		if _, ok := refs[s.Ref]; ok {
+			return fmt.Errorf("duplicate series reference %d in batch", s.Ref)
+		}
+		refs[s.Ref] = struct{}{}
+	}
+	for _, s := range series {
+		if err := w.AddSeries(s.Ref, s.Labels, s.Chunks...); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -562,6 +597,7 @@ func (w *Writer) AddSymbol(sym string) error {
 	w.numSymbols++
 	w.buf1.Reset()
 	w.buf1.PutUvarintStr(sym)
+	w.symbolBytes += int64(w.buf1.Len())
 	return w.write(w.buf1.Get())
 }
 
@@ -1072,6 +1108,28 @@ type labelIndexHashEntry struct {
 	offset uint64
 }
 
+// IndexStats holds statistics about the data an index Writer has written,
+// useful for compaction tuning.
+type IndexStats struct {
+	// Symbols is the number of unique symbols in the symbol table.
+	Symbols int
+	// SymbolBytes is the number of encoded symbol bytes written to the
+	// symbol table, excluding headers and checksum.
+	SymbolBytes int64
+	// Series is the number of series written.
+	Series int
+}
+
+// Stats returns statistics about the symbols and series written so far. It is
+// typically called after Close.
+func (w *Writer) Stats() IndexStats {
+	return IndexStats{
+		Symbols:     w.numSymbols,
+		SymbolBytes: w.symbolBytes,
+		Series:      w.numSeries,
+	}
+}
+
 func (w *Writer) Close() error {
 	// Even if this fails, we need to close all the files.
 	ensureErr := w.ensureStage(idxStageDone)
@@ -1180,6 +1238,31 @@ func NewFileReader(path string, decoder PostingsDecoder) (*Reader, error) {
 	return r, nil
 }
 
+// Hooks for the madvise calls, swapped out in tests.
+var (
+	madviseSequential = fileutil.MadviseSequential
+	madviseRandom     = fileutil.MadviseRandom
+)
+
+// ApplyAccessHints advises the kernel about the index's expected access
+// patterns when the reader is backed by a memory-mapped file: the symbol
+// table is read sequentially while series and postings are looked up in
+// random order. It is a no-op for readers not backed by a raw byte slice
+// and on platforms without madvise.
+func (r *Reader) ApplyAccessHints() error {
+	b, ok := r.b.(realByteSlice)
+	if !ok {
+		return nil
+	}
+	if err := madviseSequential(b[:r.toc.Series]); err != nil {
+		return fmt.Errorf("madvise sequential: %w", err)
+	}
+	if err := madviseRandom(b[r.toc.Series:]); err != nil {
+		return fmt.Errorf("madvise random: %w", err)
+	}
+	return nil
+}
+
 func newReader(b ByteSlice, c io.Closer, postingsDecoder PostingsDecoder) (*Reader, error) {
 	r := &Reader{
 		b:        b,
@@ -1656,6 +1739,26 @@ func (r *Reader) Series(id storage.SeriesRef, builder *labels.ScratchBuilder, ch
 	return nil
 }
 
+// ChunkRefs reads the series with the given ID and writes only its chunk
+// references into refs, skipping label symbol resolution entirely. It is a
+// cheaper alternative to Series for tooling that doesn't need the labels.
+func (r *Reader) ChunkRefs(id storage.SeriesRef, refs *[]chunks.ChunkRef) error {
+	offset := id
+	// In version 2 series IDs are no longer exact references but series are 16-byte padded
+	// and the ID is the multiple of 16 of the actual position.
+	if r.version != FormatV1 {
+		offset = id * seriesByteAlign
+	}
+	d := encoding.NewDecbufUvarintAt(r.b, int(offset), castagnoliTable)
+	if d.Err() != nil {
+		return d.Err()
+	}
+	if err := r.dec.ChunkRefs(d.Get(), refs); err != nil {
+		return fmt.Errorf("read series chunk refs: %w", err)
+	}
+	return nil
+}
+
 // traversePostingOffsets traverses r's posting offsets table, starting at off, and calls cb with every label value and postings offset.
 // If cb returns false (or an error), the traversing is interrupted.
 func (r *Reader) traversePostingOffsets(ctx context.Context, off int, cb func(string, uint64) (bool, error)) error {
@@ -1776,6 +1879,115 @@ func (r *Reader) Postings(ctx context.Context, name string, values ...string) (P
 	return Merge(ctx, res...), nil
 }
 
+// PostingsCount returns the number of series referenced by the postings list
+// for the given label pair without materializing the list, so callers can
+// order matchers by selectivity and intersect smallest-first. A missing pair
+// counts as 0.
+func (r *Reader) PostingsCount(ctx context.Context, name, value string) (int, error) {
+	if r.version == FormatV1 {
+		e, ok := r.postingsV1[name]
+		if !ok {
+			return 0, nil
+		}
+		postingsOff, ok := e[value]
+		if !ok {
+			return 0, nil
+		}
+		return r.postingsCountAt(postingsOff)
+	}
+
+	e, ok := r.postings[name]
+	if !ok || len(e) == 0 || value < e[0].value {
+		return 0, nil
+	}
+	i := sort.Search(len(e), func(i int) bool { return e[i].value >= value })
+	if i == len(e) {
+		// We're past the end.
+		return 0, nil
+	}
+	if i > 0 && e[i].value != value {
+		// Need to look from previous entry.
+		i--
+	}
+
+	count := 0
+	if err := r.traversePostingOffsets(ctx, e[i].off, func(val string, postingsOff uint64) (bool, error) {
+		if val < value {
+			return true, nil
+		}
+		if val == value {
+			c, err := r.postingsCountAt(postingsOff)
+			if err != nil {
+				return false, err
+			}
+			count = c
+		}
+		return false, nil
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// postingsCountAt reads only the length prefix of the postings list at off.
+// The on-disk list is a 4-byte count followed by fixed-width 4-byte refs, so
+// the count is available without decoding or checksumming the refs.
+func (r *Reader) postingsCountAt(off uint64) (int, error) {
+	d := encoding.NewDecbufAt(r.b, int(off), nil)
+	n := d.Be32int()
+	if d.Err() != nil {
+		return 0, fmt.Errorf("get postings count: %w", d.Err())
+	}
+	return n, nil
+}
+
+// LabelValuesPrefix returns all values with the given prefix for the label
+// named name, in sorted order. The postings offset table is binary-searched
+// to the first candidate entry and traversal stops at the first value sorting
+// after the prefix, so the scan stays bounded, which suits autocomplete-style
+// lookups. An empty prefix returns all values of the label.
+func (r *Reader) LabelValuesPrefix(ctx context.Context, name, prefix string) ([]string, error) {
+	if r.version == FormatV1 {
+		e, ok := r.postingsV1[name]
+		if !ok {
+			return nil, nil
+		}
+		var values []string
+		for v := range e {
+			if strings.HasPrefix(v, prefix) {
+				values = append(values, v)
+			}
+		}
+		slices.Sort(values)
+		return values, nil
+	}
+
+	e, ok := r.postings[name]
+	if !ok || len(e) == 0 {
+		return nil, nil
+	}
+	i := sort.Search(len(e), func(i int) bool { return e[i].value >= prefix })
+	if i > 0 {
+		// The offset table is sampled, so the entry before the first one
+		// sorting at or after the prefix may still cover matching values.
+		i--
+	}
+
+	var values []string
+	lastVal := e[len(e)-1].value
+	err := r.traversePostingOffsets(ctx, e[i].off, func(val string, _ uint64) (bool, error) {
+		switch {
+		case strings.HasPrefix(val, prefix):
+			values = append(values, val)
+		case val > prefix:
+			// Values are sorted, nothing further can match.
+			return false, nil
+		}
+		return val != lastVal, nil
+	})
+	return values, err
+}
+
 func (r *Reader) PostingsForLabelMatching(ctx context.Context, name string, match func(string) bool) Postings {
 	return r.postingsForLabelMatching(ctx, name, match)
 }
@@ -1908,6 +2120,135 @@ func (r *Reader) LabelNames(_ context.Context, matchers ...*labels.Matcher) ([]s
 	return labelNames, nil
 }
 
+// Inconsistency describes a label value present in only one of a block's
+// label indices and postings offset table.
+type Inconsistency struct {
+	LabelName  string
+	LabelValue string
+	// MissingFrom names the section the value is absent from, either
+	// "postings" or "label index".
+	MissingFrom string
+}
+
+// CheckLabelPostingsConsistency cross-checks, for every label name, the
+// values recorded in the label indices against the values present in the
+// postings offset table and reports every mismatch. A consistent block
+// returns no inconsistencies.
+func (r *Reader) CheckLabelPostingsConsistency(ctx context.Context) ([]Inconsistency, error) {
+	// Collect the label values present in the postings offset table.
+	postingsValues := map[string]map[string]struct{}{}
+	if err := ReadPostingsOffsetTable(r.b, r.toc.PostingsTable, func(name, value []byte, _ uint64, _ int) error {
+		if len(name) == 0 {
+			// The all-postings entry is not from any metric.
+			return nil
+		}
+		n := string(name)
+		vs, ok := postingsValues[n]
+		if !ok {
+			vs = map[string]struct{}{}
+			postingsValues[n] = vs
+		}
+		vs[string(value)] = struct{}{}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("read postings offset table: %w", err)
+	}
+
+	// Walk the label indices offset table and decode each label index.
+	indexValues := map[string]map[string]struct{}{}
+	d := encoding.NewDecbufAt(r.b, int(r.toc.LabelIndicesTable), castagnoliTable)
+	cnt := d.Be32()
+	for d.Err() == nil && d.Len() > 0 && cnt > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if keyCount := d.Uvarint(); keyCount != 1 {
+			return nil, fmt.Errorf("unexpected number of keys for label indices table %d", keyCount)
+		}
+		name := d.UvarintStr()
+		off := d.Uvarint64()
+		if d.Err() != nil {
+			break
+		}
+
+		ed := encoding.NewDecbufAt(r.b, int(off), castagnoliTable)
+		if nNames := ed.Be32int(); nNames != 1 {
+			return nil, fmt.Errorf("unexpected number of names in label index for %q: %d", name, nNames)
+		}
+		nEntries := ed.Be32int()
+		vs := map[string]struct{}{}
+		for i := 0; i < nEntries && ed.Err() == nil; i++ {
+			v, err := r.lookupSymbol(ctx, ed.Be32())
+			if err != nil {
+				return nil, fmt.Errorf("lookup label index value for %q: %w", name, err)
+			}
+			vs[v] = struct{}{}
+		}
+		if ed.Err() != nil {
+			return nil, fmt.Errorf("read label index for %q: %w", name, ed.Err())
+		}
+		indexValues[name] = vs
+		cnt--
+	}
+	if d.Err() != nil {
+		return nil, fmt.Errorf("read label indices table: %w", d.Err())
+	}
+
+	names := make([]string, 0, len(postingsValues))
+	for n := range postingsValues {
+		names = append(names, n)
+	}
+	for n := range indexValues {
+		if _, ok := postingsValues[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	slices.Sort(names)
+
+	var inconsistencies []Inconsistency
+	for _, n := range names {
+		var vals []string
+		for v := range indexValues[n] {
+			if _, ok := postingsValues[n][v]; !ok {
+				vals = append(vals, v)
+			}
+		}
+		slices.Sort(vals)
+		for _, v := range vals {
+			inconsistencies = append(inconsistencies, Inconsistency{LabelName: n, LabelValue: v, MissingFrom: "postings"})
+		}
+		vals = vals[:0]
+		for v := range postingsValues[n] {
+			if _, ok := indexValues[n][v]; !ok {
+				vals = append(vals, v)
+			}
+		}
+		slices.Sort(vals)
+		for _, v := range vals {
+			inconsistencies = append(inconsistencies, Inconsistency{LabelName: n, LabelValue: v, MissingFrom: "label index"})
+		}
+	}
+	return inconsistencies, nil
+}
+
+// SchemaFingerprint returns a hash over the sorted set of label names present
+// in the index. Blocks with identical label-name sets produce identical
+// fingerprints, which lets tooling detect schema drift and group
+// schema-compatible blocks.
+func (r *Reader) SchemaFingerprint() (uint64, error) {
+	labelNames, err := r.LabelNames(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	h := xxhash.New()
+	for _, name := range labelNames {
+		_, _ = h.WriteString(name)
+		// Separate names to avoid ambiguity between concatenations.
+		_, _ = h.Write([]byte{0xff})
+	}
+	return h.Sum64(), nil
+}
+
 // NewStringListIter returns a StringIter for the given sorted list of strings.
 func NewStringListIter(s []string) StringIter {
 	return &stringListIter{l: s}
@@ -2078,6 +2419,46 @@ func (dec *Decoder) Series(b []byte, builder *labels.ScratchBuilder, chks *[]chu
 	return d.Err()
 }
 
+// ChunkRefs decodes only the chunk references of a series entry into refs,
+// skipping the label section without resolving any symbols.
+func (dec *Decoder) ChunkRefs(b []byte, refs *[]chunks.ChunkRef) error {
+	*refs = (*refs)[:0]
+
+	d := encoding.Decbuf{B: b}
+
+	// Skip the label offset pairs without symbol lookups.
+	k := d.Uvarint()
+	for i := 0; i < k; i++ {
+		d.Uvarint()
+		d.Uvarint()
+	}
+	if d.Err() != nil {
+		return fmt.Errorf("read series label offsets: %w", d.Err())
+	}
+
+	k = d.Uvarint()
+	if k == 0 {
+		return d.Err()
+	}
+
+	d.Varint64()  // Minimum time.
+	d.Uvarint64() // Maximum time delta.
+	ref0 := int64(d.Uvarint64())
+	*refs = append(*refs, chunks.ChunkRef(ref0))
+
+	for i := 1; i < k; i++ {
+		d.Uvarint64() // Minimum time delta.
+		d.Uvarint64() // Maximum time delta.
+
+		ref0 += d.Varint64()
+		if d.Err() != nil {
+			return fmt.Errorf("read ref for chunk %d: %w", i, d.Err())
+		}
+		*refs = append(*refs, chunks.ChunkRef(ref0))
+	}
+	return d.Err()
+}
+
 func yoloString(b []byte) string {
 	return unsafe.String(unsafe.SliceData(b), len(b))
 }
@@ -15,11 +15,13 @@ package index
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"hash/crc32"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"sort"
 	"strconv"
@@ -459,6 +461,98 @@ func TestWriter_ShouldReturnErrorOnSeriesWithDuplicatedLabelNames(t *testing.T)
 	require.ErrorContains(t, err, "corruption detected when writing postings to index")
 }
 
+func TestWriter_AddSeriesBatch(t *testing.T) {
+	ctx := context.Background()
+
+	var input indexWriterSeriesSlice
+	for i := 1; i < 5; i++ {
+		input = append(input, &indexWriterSeries{
+			labels: labels.FromStrings("a", "1", "b", strconv.Itoa(i)),
+			chunks: []chunks.Meta{{Ref: chunks.ChunkRef(i), MinTime: int64(i * 10), MaxTime: int64(i*10 + 5)}},
+		})
+	}
+
+	// Write one index through per-call AddSeries and one from a single batch,
+	// the resulting files must be identical.
+	_, perCallFn, symbols := createFileReader(ctx, t, input)
+
+	batchFn := filepath.Join(t.TempDir(), indexFilename)
+	iw, err := NewWriter(ctx, batchFn)
+	require.NoError(t, err)
+
+	syms := []string{}
+	for s := range symbols {
+		syms = append(syms, s)
+	}
+	slices.Sort(syms)
+	for _, s := range syms {
+		require.NoError(t, iw.AddSymbol(s))
+	}
+
+	batch := make([]SeriesWithChunks, 0, len(input))
+	for i, s := range input {
+		batch = append(batch, SeriesWithChunks{Ref: storage.SeriesRef(i), Labels: s.labels, Chunks: s.chunks})
+	}
+	require.NoError(t, iw.AddSeriesBatch(batch))
+	require.NoError(t, iw.Close())
+
+	expected, err := os.ReadFile(perCallFn)
+	require.NoError(t, err)
+	actual, err := os.ReadFile(batchFn)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestWriter_AddSeriesBatchRejectsDuplicateRefs(t *testing.T) {
+	iw, err := NewWriter(context.Background(), filepath.Join(t.TempDir(), indexFilename))
+	require.NoError(t, err)
+
+	require.NoError(t, iw.AddSymbol("1"))
+	require.NoError(t, iw.AddSymbol("2"))
+	require.NoError(t, iw.AddSymbol("a"))
+
+	err = iw.AddSeriesBatch([]SeriesWithChunks{
+		{Ref: 1, Labels: labels.FromStrings("a", "1")},
+		{Ref: 1, Labels: labels.FromStrings("a", "2")},
+	})
+	require.ErrorContains(t, err, "duplicate series reference 1 in batch")
+}
+
+func BenchmarkWriter_AddSeriesBatch(b *testing.B) {
+	const numSeries = 100000
+
+	input := make(indexWriterSeriesSlice, 0, numSeries)
+	for i := 0; i < numSeries; i++ {
+		input = append(input, &indexWriterSeries{
+			labels: labels.FromStrings("i", fmt.Sprintf("%07d", i)),
+		})
+	}
+	syms := []string{"i"}
+	for _, s := range input {
+		syms = append(syms, s.labels.Get("i"))
+	}
+	slices.Sort(syms)
+
+	newWriter := func(b *testing.B) *Writer {
+		iw, err := NewWriter(context.Background(), filepath.Join(b.TempDir(), indexFilename))
+		require.NoError(b, err)
+		for _, s := range syms {
+			require.NoError(b, iw.AddSymbol(s))
+		}
+		return iw
+	}
+
+	batch := make([]SeriesWithChunks, 0, len(input))
+	for i, s := range input {
+		batch = append(batch, SeriesWithChunks{Ref: storage.SeriesRef(i), Labels: s.labels, Chunks: s.chunks})
+	}
+	for n := 0; n < b.N; n++ {
+		iw := newWriter(b)
+		require.NoError(b, iw.AddSeriesBatch(batch))
+		require.NoError(b, iw.Close())
+	}
+}
+
 func TestDecbufUvarintWithInvalidBuffer(t *testing.T) {
 	b := realByteSlice([]byte{0x81, 0x81, 0x81, 0x81, 0x81, 0x81})
 
@@ -639,6 +733,164 @@ func TestReader_PostingsForLabelMatching(t *testing.T) {
 	require.Equal(t, []storage.SeriesRef{4, 6, 8, 10}, refs)
 }
 
+func TestReader_ChunkRefs(t *testing.T) {
+	ctx := context.Background()
+
+	var input indexWriterSeriesSlice
+	for i := 1; i < 5; i++ {
+		input = append(input, &indexWriterSeries{
+			labels: labels.FromStrings("a", "1", "b", strconv.Itoa(i)),
+			chunks: []chunks.Meta{
+				{Ref: chunks.ChunkRef(2 * i), MinTime: int64(i * 10), MaxTime: int64(i*10 + 5)},
+				{Ref: chunks.ChunkRef(2*i + 1), MinTime: int64(i*10 + 6), MaxTime: int64(i*10 + 9)},
+			},
+		})
+	}
+	ir, _, _ := createFileReader(ctx, t, input)
+
+	name, value := AllPostingsKey()
+	p, err := ir.Postings(ctx, name, value)
+	require.NoError(t, err)
+	seriesRefs, err := ExpandPostings(p)
+	require.NoError(t, err)
+	require.Len(t, seriesRefs, len(input))
+
+	// Collect the expected refs through the full Series call first.
+	var builder labels.ScratchBuilder
+	var chks []chunks.Meta
+	expected := make([][]chunks.ChunkRef, len(seriesRefs))
+	for i, ref := range seriesRefs {
+		require.NoError(t, ir.Series(ref, &builder, &chks))
+		for _, c := range chks {
+			expected[i] = append(expected[i], c.Ref)
+		}
+	}
+
+	// ChunkRefs must return the same refs without any symbol lookups.
+	ir.dec.LookupSymbol = func(context.Context, uint32) (string, error) {
+		t.Fatal("unexpected symbol lookup")
+		return "", nil
+	}
+	var refs []chunks.ChunkRef
+	for i, ref := range seriesRefs {
+		require.NoError(t, ir.ChunkRefs(ref, &refs))
+		require.Equal(t, expected[i], refs)
+	}
+}
+
+func TestReader_LabelValuesPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	var input indexWriterSeriesSlice
+	for i, v := range []string{"alpha", "alphabet", "beta", "betamax", "gamma"} {
+		input = append(input, &indexWriterSeries{
+			labels: labels.FromStrings("v", v),
+			chunks: []chunks.Meta{
+				{Ref: chunks.ChunkRef(i), MinTime: 0, MaxTime: 10},
+			},
+		})
+	}
+	ir, _, _ := createFileReader(ctx, t, input)
+
+	for _, tc := range []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "", want: []string{"alpha", "alphabet", "beta", "betamax", "gamma"}},
+		{prefix: "alpha", want: []string{"alpha", "alphabet"}},
+		{prefix: "beta", want: []string{"beta", "betamax"}},
+		{prefix: "g", want: []string{"gamma"}},
+		{prefix: "delta", want: nil},
+		{prefix: "zeta", want: nil},
+	} {
+		values, err := ir.LabelValuesPrefix(ctx, "v", tc.prefix)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, values, "prefix %q", tc.prefix)
+	}
+
+	// Missing label names return no values.
+	values, err := ir.LabelValuesPrefix(ctx, "missing", "a")
+	require.NoError(t, err)
+	require.Empty(t, values)
+}
+
+func TestWriterStats(t *testing.T) {
+	dir := t.TempDir()
+
+	iw, err := NewWriter(context.Background(), filepath.Join(dir, indexFilename))
+	require.NoError(t, err)
+
+	// Unique symbols in sorted order.
+	symbols := []string{"1", "2", "a", "b", "instance", "job"}
+	for _, s := range symbols {
+		require.NoError(t, iw.AddSymbol(s))
+	}
+	require.NoError(t, iw.AddSeries(1, labels.FromStrings("job", "a", "instance", "1")))
+	require.NoError(t, iw.AddSeries(2, labels.FromStrings("job", "b", "instance", "1")))
+	require.NoError(t, iw.AddSeries(3, labels.FromStrings("job", "a", "instance", "2")))
+	require.NoError(t, iw.Close())
+
+	// Each symbol is encoded as a uvarint length prefix (1 byte for these)
+	// plus its bytes.
+	var symbolBytes int64
+	for _, s := range symbols {
+		symbolBytes += int64(1 + len(s))
+	}
+	require.Equal(t, IndexStats{
+		Symbols:     len(symbols),
+		SymbolBytes: symbolBytes,
+		Series:      3,
+	}, iw.Stats())
+}
+
+func TestReader_PostingsCount(t *testing.T) {
+	ctx := context.Background()
+
+	var input indexWriterSeriesSlice
+	for i := 1; i <= 20; i++ {
+		input = append(input, &indexWriterSeries{
+			labels: labels.FromStrings("a", "1", "b", strconv.Itoa(i%4), "c", strconv.Itoa(i)),
+			chunks: []chunks.Meta{
+				{Ref: chunks.ChunkRef(i), MinTime: 0, MaxTime: 10},
+			},
+		})
+	}
+	sort.Sort(input)
+	// Chunk references must be increasing in write order.
+	for i, s := range input {
+		s.chunks[0].Ref = chunks.ChunkRef(i)
+	}
+	ir, _, _ := createFileReader(ctx, t, input)
+
+	for _, pair := range []struct{ name, value string }{
+		{"a", "1"},
+		{"b", "0"},
+		{"b", "3"},
+		{"c", "7"},
+		{"c", "20"},
+	} {
+		p, err := ir.Postings(ctx, pair.name, pair.value)
+		require.NoError(t, err)
+		refs, err := ExpandPostings(p)
+		require.NoError(t, err)
+
+		count, err := ir.PostingsCount(ctx, pair.name, pair.value)
+		require.NoError(t, err)
+		require.Equal(t, len(refs), count, "label pair %s=%s", pair.name, pair.value)
+	}
+
+	// Missing label pairs count as 0.
+	for _, pair := range []struct{ name, value string }{
+		{"a", "2"},
+		{"b", "9"},
+		{"missing", "1"},
+	} {
+		count, err := ir.PostingsCount(ctx, pair.name, pair.value)
+		require.NoError(t, err)
+		require.Equal(t, 0, count, "label pair %s=%s", pair.name, pair.value)
+	}
+}
+
 func TestReader_PostingsForAllLabelValues(t *testing.T) {
 	const seriesCount = 9
 	var input indexWriterSeriesSlice
@@ -744,3 +996,155 @@ func createFileReader(ctx context.Context, tb testing.TB, input indexWriterSerie
 	})
 	return ir, fn, symbols
 }
+
+func TestApplyAccessHints(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("madvise hints are only verified on linux")
+	}
+
+	fn := filepath.Join(t.TempDir(), indexFilename)
+
+	w, err := NewWriter(context.Background(), fn)
+	require.NoError(t, err)
+	require.NoError(t, w.AddSymbol("1"))
+	require.NoError(t, w.AddSymbol("a"))
+	require.NoError(t, w.AddSeries(1, labels.FromStrings("a", "1")))
+	require.NoError(t, w.Close())
+
+	ir, err := NewFileReader(fn, DecodePostingsRaw)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, ir.Close()) })
+
+	var sequential, random int
+	oldSequential, oldRandom := madviseSequential, madviseRandom
+	t.Cleanup(func() { madviseSequential, madviseRandom = oldSequential, oldRandom })
+	madviseSequential = func(b []byte) error {
+		sequential++
+		return oldSequential(b)
+	}
+	madviseRandom = func(b []byte) error {
+		random++
+		return oldRandom(b)
+	}
+
+	require.NoError(t, ir.ApplyAccessHints())
+	require.Equal(t, 1, sequential)
+	require.Equal(t, 1, random)
+
+	// Reads still succeed after the hints are applied.
+	p, err := ir.Postings(context.Background(), "a", "1")
+	require.NoError(t, err)
+	require.True(t, p.Next())
+	var builder labels.ScratchBuilder
+	require.NoError(t, ir.Series(p.At(), &builder, nil))
+	require.Equal(t, labels.FromStrings("a", "1"), builder.Labels())
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	buildIndex := func(t *testing.T, symbols []string, series []labels.Labels) *Reader {
+		fn := filepath.Join(t.TempDir(), indexFilename)
+
+		w, err := NewWriter(context.Background(), fn)
+		require.NoError(t, err)
+
+		for _, s := range symbols {
+			require.NoError(t, w.AddSymbol(s))
+		}
+		for i, lset := range series {
+			require.NoError(t, w.AddSeries(storage.SeriesRef(i), lset))
+		}
+		require.NoError(t, w.Close())
+
+		ir, err := NewFileReader(fn, DecodePostingsRaw)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, ir.Close()) })
+		return ir
+	}
+
+	ir1 := buildIndex(t,
+		[]string{"1", "2", "a", "b"},
+		[]labels.Labels{
+			labels.FromStrings("a", "1", "b", "1"),
+			labels.FromStrings("a", "2", "b", "2"),
+		})
+	// Same label names, different values and series.
+	ir2 := buildIndex(t,
+		[]string{"1", "a", "b"},
+		[]labels.Labels{labels.FromStrings("a", "1", "b", "1")})
+	// One additional label name.
+	ir3 := buildIndex(t,
+		[]string{"1", "a", "b", "c"},
+		[]labels.Labels{labels.FromStrings("a", "1", "b", "1", "c", "1")})
+
+	fp1, err := ir1.SchemaFingerprint()
+	require.NoError(t, err)
+	fp2, err := ir2.SchemaFingerprint()
+	require.NoError(t, err)
+	fp3, err := ir3.SchemaFingerprint()
+	require.NoError(t, err)
+
+	require.Equal(t, fp1, fp2)
+	require.NotEqual(t, fp1, fp3)
+}
+
+func TestCheckLabelPostingsConsistency(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), indexFilename)
+
+	w, err := NewWriter(context.Background(), fn)
+	require.NoError(t, err)
+	for _, s := range []string{"1", "2", "a", "b"} {
+		require.NoError(t, w.AddSymbol(s))
+	}
+	require.NoError(t, w.AddSeries(0, labels.FromStrings("a", "1", "b", "1")))
+	require.NoError(t, w.AddSeries(1, labels.FromStrings("a", "2", "b", "1")))
+	require.NoError(t, w.Close())
+
+	// A freshly written block is consistent.
+	ir, err := NewFileReader(fn, DecodePostingsRaw)
+	require.NoError(t, err)
+	inconsistencies, err := ir.CheckLabelPostingsConsistency(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, inconsistencies)
+
+	bID, err := ir.symbols.ReverseLookup("b")
+	require.NoError(t, err)
+	require.NoError(t, ir.Close())
+
+	// Hand-edit the label index of "a": point its second value at the
+	// symbol for "b" and fix up the entry checksum, so the label index
+	// claims a=b while postings know a=1 and a=2.
+	data, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	toc, err := NewTOCFromByteSlice(realByteSlice(data))
+	require.NoError(t, err)
+
+	d := encoding.NewDecbufAt(realByteSlice(data), int(toc.LabelIndicesTable), castagnoliTable)
+	var aOff int
+	for cnt := d.Be32(); cnt > 0; cnt-- {
+		require.Equal(t, 1, d.Uvarint())
+		name := d.UvarintStr()
+		off := d.Uvarint64()
+		require.NoError(t, d.Err())
+		if name == "a" {
+			aOff = int(off)
+		}
+	}
+	require.NotZero(t, aOff)
+
+	l := int(binary.BigEndian.Uint32(data[aOff:]))
+	require.Equal(t, 2, int(binary.BigEndian.Uint32(data[aOff+8:]))) // Two values for "a".
+	binary.BigEndian.PutUint32(data[aOff+16:], bID)                  // Second value symbol ref.
+	binary.BigEndian.PutUint32(data[aOff+4+l:], crc32.Checksum(data[aOff+4:aOff+4+l], castagnoliTable))
+	require.NoError(t, os.WriteFile(fn, data, 0o666))
+
+	ir, err = NewFileReader(fn, DecodePostingsRaw)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, ir.Close()) }()
+
+	inconsistencies, err = ir.CheckLabelPostingsConsistency(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Inconsistency{
+		{LabelName: "a", LabelValue: "b", MissingFrom: "postings"},
+		{LabelName: "a", LabelValue: "2", MissingFrom: "label index"},
+	}, inconsistencies)
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wlog
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/record"
+)
+
+// DumpOptions configures the output of DumpSegmentWAL.
+type DumpOptions struct {
+	// MinTime and MaxTime bound the samples and tombstone intervals that
+	// are printed, both inclusive. A zero MaxTime means no upper bound.
+	MinTime, MaxTime int64
+	// Ref limits the output to entries for the given series reference.
+	// Zero means all series.
+	Ref chunks.HeadSeriesRef
+}
+
+// DumpSegmentWAL reads every segment in dir and writes a human-readable line
+// per series, samples and tombstones entry to w, for debugging. Samples
+// records are summarized as a count and time range. The segments are opened
+// read-only and never modified, so it is safe to run against the WAL of a
+// stopped Prometheus. Records of other types are skipped.
+func DumpSegmentWAL(dir string, w io.Writer, opts DumpOptions) error {
+	if opts.MaxTime == 0 {
+		opts.MaxTime = math.MaxInt64
+	}
+	segs, err := listSegments(dir)
+	if err != nil {
+		return fmt.Errorf("list segments: %w", err)
+	}
+	dec := record.NewDecoder(labels.NewSymbolTable())
+	for _, sref := range segs {
+		s, err := OpenReadSegment(filepath.Join(dir, sref.name))
+		if err != nil {
+			return fmt.Errorf("open segment %d: %w", sref.index, err)
+		}
+		err = dumpSegment(s, w, &dec, opts)
+		s.Close()
+		if err != nil {
+			return fmt.Errorf("dump segment %d: %w", sref.index, err)
+		}
+	}
+	return nil
+}
+
+func dumpSegment(s *Segment, w io.Writer, dec *record.Decoder, opts DumpOptions) error {
+	r := NewReader(NewSegmentBufReader(s))
+	for {
+		// The offset before reading the next record is where that
+		// record starts, modulo page padding.
+		off := r.Offset()
+		if !r.Next() {
+			break
+		}
+		rec := r.Record()
+		switch dec.Type(rec) {
+		case record.Series:
+			series, err := dec.Series(rec, nil)
+			if err != nil {
+				return fmt.Errorf("decode series record: %w", err)
+			}
+			for _, e := range series {
+				if opts.Ref != 0 && e.Ref != opts.Ref {
+					continue
+				}
+				fmt.Fprintf(w, "series segment=%d offset=%d ref=%d labels=%s\n", s.Index(), off, e.Ref, e.Labels)
+			}
+		case record.Samples:
+			samples, err := dec.Samples(rec, nil)
+			if err != nil {
+				return fmt.Errorf("decode samples record: %w", err)
+			}
+			count, mint, maxt := 0, int64(math.MaxInt64), int64(math.MinInt64)
+			for _, e := range samples {
+				if opts.Ref != 0 && e.Ref != opts.Ref {
+					continue
+				}
+				if e.T < opts.MinTime || e.T > opts.MaxTime {
+					continue
+				}
+				count++
+				mint = min(mint, e.T)
+				maxt = max(maxt, e.T)
+			}
+			if count > 0 {
+				fmt.Fprintf(w, "samples segment=%d offset=%d count=%d mint=%d maxt=%d\n", s.Index(), off, count, mint, maxt)
+			}
+		case record.Tombstones:
+			tstones, err := dec.Tombstones(rec, nil)
+			if err != nil {
+				return fmt.Errorf("decode tombstones record: %w", err)
+			}
+			for _, e := range tstones {
+				if opts.Ref != 0 && chunks.HeadSeriesRef(e.Ref) != opts.Ref {
+					continue
+				}
+				for _, iv := range e.Intervals {
+					if iv.Maxt < opts.MinTime || iv.Mint > opts.MaxTime {
+						continue
+					}
+					fmt.Fprintf(w, "tombstones segment=%d offset=%d ref=%d mint=%d maxt=%d\n", s.Index(), off, e.Ref, iv.Mint, iv.Maxt)
+				}
+			}
+		}
+	}
+	return r.Err()
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wlog
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+)
+
+func TestDumpSegmentWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := New(nil, nil, dir, CompressionNone)
+	require.NoError(t, err)
+	var enc record.Encoder
+	require.NoError(t, w.Log(enc.Series([]record.RefSeries{
+		{Ref: 1, Labels: labels.FromStrings("a", "b")},
+		{Ref: 2, Labels: labels.FromStrings("c", "d")},
+	}, nil)))
+	require.NoError(t, w.Log(enc.Samples([]record.RefSample{
+		{Ref: 1, T: 100, V: 1},
+		{Ref: 1, T: 200, V: 2},
+		{Ref: 2, T: 300, V: 3},
+	}, nil)))
+	require.NoError(t, w.Log(enc.Tombstones([]tombstones.Stone{
+		{Ref: 1, Intervals: tombstones.Intervals{{Mint: 0, Maxt: 150}}},
+	}, nil)))
+	require.NoError(t, w.Close())
+
+	// Offsets depend on record framing, so blank them out before comparing.
+	dump := func(opts DumpOptions) string {
+		var buf bytes.Buffer
+		require.NoError(t, DumpSegmentWAL(dir, &buf, opts))
+		return regexp.MustCompile(`offset=\d+`).ReplaceAllString(buf.String(), "offset=?")
+	}
+
+	require.Equal(t, `series segment=0 offset=? ref=1 labels={a="b"}
+series segment=0 offset=? ref=2 labels={c="d"}
+samples segment=0 offset=? count=3 mint=100 maxt=300
+tombstones segment=0 offset=? ref=1 mint=0 maxt=150
+`, dump(DumpOptions{}))
+
+	require.Equal(t, `series segment=0 offset=? ref=1 labels={a="b"}
+samples segment=0 offset=? count=2 mint=100 maxt=200
+tombstones segment=0 offset=? ref=1 mint=0 maxt=150
+`, dump(DumpOptions{Ref: 1}))
+
+	require.Equal(t, `series segment=0 offset=? ref=1 labels={a="b"}
+series segment=0 offset=? ref=2 labels={c="d"}
+samples segment=0 offset=? count=1 mint=200 maxt=200
+`, dump(DumpOptions{MinTime: 160, MaxTime: 250}))
+}
@@ -15,6 +15,7 @@
 package wlog
 
 import (
+	"crypto/cipher"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -32,6 +33,7 @@ type Reader struct {
 	rec         []byte
 	compressBuf []byte
 	zstdReader  *zstd.Decoder
+	aead        cipher.AEAD // Non-nil when encrypted records can be read.
 	buf         [pageSize]byte
 	total       int64   // Total bytes processed.
 	curRecTyp   recType // Used for checking that the last record is not torn.
@@ -44,6 +46,18 @@ func NewReader(r io.Reader) *Reader {
 	return &Reader{rdr: r, zstdReader: zstdReader}
 }
 
+// SetDecryptionKey enables reading records that were encrypted at rest with
+// the given AES key (16, 24 or 32 bytes). Unencrypted records are read as
+// usual; encrypted records encountered without a key set produce an error.
+func (r *Reader) SetDecryptionKey(key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	r.aead = aead
+	return nil
+}
+
 // Next advances the reader to the next records and returns true if it exists.
 // It must not be called again after it returned false.
 func (r *Reader) Next() bool {
@@ -79,6 +93,7 @@ func (r *Reader) next() (err error) {
 		r.curRecTyp = recTypeFromHeader(hdr[0])
 		isSnappyCompressed := hdr[0]&snappyMask == snappyMask
 		isZstdCompressed := hdr[0]&zstdMask == zstdMask
+		isEncrypted := hdr[0]&encryptedMask == encryptedMask
 
 		// Gobble up zero bytes.
 		if r.curRecTyp == recPageTerm {
@@ -134,7 +149,7 @@ func (r *Reader) next() (err error) {
 			return fmt.Errorf("unexpected checksum %x, expected %x", c, crc)
 		}
 
-		if isSnappyCompressed || isZstdCompressed {
+		if isSnappyCompressed || isZstdCompressed || isEncrypted {
 			r.compressBuf = append(r.compressBuf, buf[:length]...)
 		} else {
 			r.rec = append(r.rec, buf[:length]...)
@@ -144,6 +159,24 @@ func (r *Reader) next() (err error) {
 			return err
 		}
 		if r.curRecTyp == recLast || r.curRecTyp == recFull {
+			if isEncrypted {
+				if r.aead == nil {
+					return errors.New("record is encrypted and no decryption key was set")
+				}
+				if len(r.compressBuf) < r.aead.NonceSize() {
+					return errors.New("encrypted record too short to contain a nonce")
+				}
+				nonce, ciphertext := r.compressBuf[:r.aead.NonceSize()], r.compressBuf[r.aead.NonceSize():]
+				decrypted, err := r.aead.Open(nil, nonce, ciphertext, nil)
+				if err != nil {
+					return fmt.Errorf("decrypt record: %w", err)
+				}
+				if !isSnappyCompressed && !isZstdCompressed {
+					r.rec = append(r.rec[:0], decrypted...)
+					return nil
+				}
+				r.compressBuf = decrypted
+			}
 			if isSnappyCompressed && len(r.compressBuf) > 0 {
 				// The snappy library uses `len` to calculate if we need a new buffer.
 				// In order to allocate as few buffers as possible make the length
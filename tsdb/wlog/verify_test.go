@@ -0,0 +1,69 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wlog
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+
+	segSize := 3 * pageSize
+	w, err := NewSize(nil, nil, dir, segSize, CompressionNone)
+	require.NoError(t, err)
+
+	for i := 0; i < 9; i++ {
+		b := make([]byte, pageSize-recordHeaderSize)
+		b[0] = byte(i)
+		require.NoError(t, w.Log(b))
+	}
+	require.NoError(t, w.Close())
+
+	// A clean WAL verifies without corruptions.
+	report, err := Verify(dir, nil)
+	require.NoError(t, err)
+	require.False(t, report.Corrupted())
+	require.Equal(t, 9, report.Records)
+	require.Positive(t, report.BytesScanned)
+
+	// Corrupt the checksum of a record in the second segment.
+	f, err := os.OpenFile(SegmentName(dir, 1), os.O_RDWR, 0o666)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0}, 4)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	before, err := os.ReadFile(SegmentName(dir, 1))
+	require.NoError(t, err)
+
+	report, err = Verify(dir, nil)
+	require.NoError(t, err)
+	require.True(t, report.Corrupted())
+
+	// The first segment is still intact, the corrupted one reports the
+	// bad position.
+	require.Nil(t, report.Segments[0].Corruption)
+	require.Equal(t, 3, report.Segments[0].Records)
+	require.NotNil(t, report.Segments[1].Corruption)
+	require.Equal(t, 1, report.Segments[1].Corruption.Segment)
+
+	// Verification must not modify any files.
+	after, err := os.ReadFile(SegmentName(dir, 1))
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+}
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -78,6 +79,7 @@ type WatcherMetrics struct {
 	samplesSentPreTailing *prometheus.CounterVec
 	currentSegment        *prometheus.GaugeVec
 	notificationsSkipped  *prometheus.CounterVec
+	checkpointReads       *prometheus.CounterVec
 }
 
 // Watcher watches the TSDB WAL for a given WriteTo.
@@ -97,11 +99,14 @@ type Watcher struct {
 	startTimestamp int64 // the start time as a Prometheus timestamp
 	sendSamples    bool
 
+	forwardedMark atomic.Int64 // Last successfully forwarded sample timestamp, persisted across restarts.
+
 	recordsReadMetric       *prometheus.CounterVec
 	recordDecodeFailsMetric prometheus.Counter
 	samplesSentPreTailing   prometheus.Counter
 	currentSegmentMetric    prometheus.Gauge
 	notificationsSkipped    prometheus.Counter
+	checkpointReadsMetric   prometheus.Counter
 
 	readNotify chan struct{}
 	quit       chan struct{}
@@ -158,6 +163,15 @@ func NewWatcherMetrics(reg prometheus.Registerer) *WatcherMetrics {
 			},
 			[]string{consumer},
 		),
+		checkpointReads: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "prometheus",
+				Subsystem: "wal_watcher",
+				Name:      "checkpoint_reads_total",
+				Help:      "Number of checkpoints read by the WAL watcher.",
+			},
+			[]string{consumer},
+		),
 	}
 
 	if reg != nil {
@@ -166,6 +180,7 @@ func NewWatcherMetrics(reg prometheus.Registerer) *WatcherMetrics {
 		reg.MustRegister(m.samplesSentPreTailing)
 		reg.MustRegister(m.currentSegment)
 		reg.MustRegister(m.notificationsSkipped)
+		reg.MustRegister(m.checkpointReads)
 	}
 
 	return m
@@ -176,7 +191,7 @@ func NewWatcher(metrics *WatcherMetrics, readerMetrics *LiveReaderMetrics, logge
 	if logger == nil {
 		logger = promslog.NewNopLogger()
 	}
-	return &Watcher{
+	w := &Watcher{
 		logger:         logger,
 		writer:         writer,
 		metrics:        metrics,
@@ -193,6 +208,8 @@ func NewWatcher(metrics *WatcherMetrics, readerMetrics *LiveReaderMetrics, logge
 
 		MaxSegment: -1,
 	}
+	w.loadForwardedMark()
+	return w
 }
 
 func (w *Watcher) Notify() {
@@ -216,6 +233,7 @@ func (w *Watcher) SetMetrics() {
 		w.samplesSentPreTailing = w.metrics.samplesSentPreTailing.WithLabelValues(w.name)
 		w.currentSegmentMetric = w.metrics.currentSegment.WithLabelValues(w.name)
 		w.notificationsSkipped = w.metrics.notificationsSkipped.WithLabelValues(w.name)
+		w.checkpointReadsMetric = w.metrics.checkpointReads.WithLabelValues(w.name)
 	}
 }
 
@@ -239,6 +257,7 @@ func (w *Watcher) Stop() {
 		w.metrics.recordDecodeFails.DeleteLabelValues(w.name)
 		w.metrics.samplesSentPreTailing.DeleteLabelValues(w.name)
 		w.metrics.currentSegment.DeleteLabelValues(w.name)
+		w.metrics.checkpointReads.DeleteLabelValues(w.name)
 	}
 
 	w.logger.Info("WAL watcher stopped", "queue", w.name)
@@ -664,6 +683,51 @@ func (w *Watcher) readSegmentForGC(r *LiveReader, segmentNum int, _ bool) error
 func (w *Watcher) SetStartTime(t time.Time) {
 	w.startTime = t
 	w.startTimestamp = timestamp.FromTime(t)
+	// A recorded forwarded mark takes precedence so that delivery resumes
+	// exactly where the consumer left off instead of at the start time.
+	if mark := w.forwardedMark.Load(); mark != 0 {
+		w.startTimestamp = mark
+	}
+}
+
+// markFile is where the forwarded mark for this watcher is persisted,
+// stored next to the wal directory to keep the WAL itself untouched.
+func (w *Watcher) markFile() string {
+	return filepath.Join(filepath.Dir(w.walDir), "wal_watcher_marks", w.name)
+}
+
+// RecordForwardedTimestamp persists the timestamp of the last sample the
+// consumer has successfully forwarded. After a restart the watcher resumes
+// from this mark, skipping samples that were already delivered.
+func (w *Watcher) RecordForwardedTimestamp(ts int64) error {
+	w.forwardedMark.Store(ts)
+
+	fn := w.markFile()
+	if err := os.MkdirAll(filepath.Dir(fn), 0o777); err != nil {
+		return err
+	}
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(ts, 10)), 0o666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// loadForwardedMark reads the persisted forwarded mark, if any.
+func (w *Watcher) loadForwardedMark() {
+	b, err := os.ReadFile(w.markFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			w.logger.Error("Failed to read WAL watcher forwarded mark", "file", w.markFile(), "err", err)
+		}
+		return
+	}
+	ts, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		w.logger.Error("Invalid WAL watcher forwarded mark", "file", w.markFile(), "err", err)
+		return
+	}
+	w.forwardedMark.Store(ts)
 }
 
 type segmentReadFn func(w *Watcher, r *LiveReader, segmentNum int, tail bool) error
@@ -671,6 +735,9 @@ type segmentReadFn func(w *Watcher, r *LiveReader, segmentNum int, tail bool) er
 // Read all the series records from a Checkpoint directory.
 func (w *Watcher) readCheckpoint(checkpointDir string, readFn segmentReadFn) error {
 	w.logger.Debug("Reading checkpoint", "dir", checkpointDir)
+	if w.checkpointReadsMetric != nil {
+		w.checkpointReadsMetric.Inc()
+	}
 	index, err := checkpointNum(checkpointDir)
 	if err != nil {
 		return fmt.Errorf("checkpointNum: %w", err)
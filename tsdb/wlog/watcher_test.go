@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	client_testutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/promslog"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
@@ -261,6 +262,58 @@ func TestTailSamples(t *testing.T) {
 	}
 }
 
+func TestWatcherResumesFromForwardedMark(t *testing.T) {
+	pageSize := 32 * 1024
+	const samplesCount = 100
+
+	dir := t.TempDir()
+	wdir := path.Join(dir, "wal")
+	require.NoError(t, os.Mkdir(wdir, 0o777))
+
+	enc := record.Encoder{}
+	w, err := NewSize(nil, nil, wdir, 128*pageSize, CompressionNone)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, w.Close())
+	}()
+
+	series := enc.Series([]record.RefSeries{
+		{Ref: 1, Labels: labels.FromStrings("__name__", "metric")},
+	}, nil)
+	require.NoError(t, w.Log(series))
+
+	// Timestamps 1..samplesCount.
+	for ts := 1; ts <= samplesCount; ts++ {
+		sample := enc.Samples([]record.RefSample{
+			{Ref: 1, T: int64(ts), V: float64(ts)},
+		}, nil)
+		require.NoError(t, w.Log(sample))
+	}
+
+	// Record a forwarded mark halfway through and restart the watcher.
+	mark := NewWatcher(wMetrics, nil, nil, "mark", nil, dir, false, false, false)
+	require.NoError(t, mark.RecordForwardedTimestamp(int64(samplesCount/2)))
+
+	wt := newWriteToMock(0)
+	watcher := NewWatcher(wMetrics, nil, nil, "mark", wt, dir, false, false, false)
+	watcher.SetStartTime(time.Unix(0, 0))
+	watcher.SetMetrics()
+
+	first, last, err := Segments(w.Dir())
+	require.NoError(t, err)
+	for i := first; i <= last; i++ {
+		segment, err := OpenReadSegment(SegmentName(watcher.walDir, i))
+		require.NoError(t, err)
+
+		reader := NewLiveReader(nil, NewLiveReaderMetrics(nil), segment)
+		watcher.readSegment(reader, i, true)
+		require.NoError(t, segment.Close())
+	}
+
+	// Only samples newer than the mark are forwarded.
+	require.Equal(t, samplesCount/2, wt.samplesAppended, "expected only samples past the forwarded mark")
+}
+
 func TestReadToEndNoCheckpoint(t *testing.T) {
 	pageSize := 32 * 1024
 	const seriesCount = 10
@@ -417,6 +470,71 @@ func TestReadToEndWithCheckpoint(t *testing.T) {
 	}
 }
 
+func TestWatcherProgressMetrics(t *testing.T) {
+	pageSize := 32 * 1024
+	const seriesCount = 10
+	const samplesCount = 250
+
+	dir := t.TempDir()
+	wdir := path.Join(dir, "wal")
+	require.NoError(t, os.Mkdir(wdir, 0o777))
+
+	enc := record.Encoder{}
+	w, err := NewSize(nil, nil, wdir, 128*pageSize, CompressionNone)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, w.Close())
+	})
+
+	// Write series and samples spread over two segments.
+	for seg := 0; seg < 2; seg++ {
+		for i := 0; i < seriesCount; i++ {
+			ref := seg*seriesCount + i
+			series := enc.Series([]record.RefSeries{
+				{
+					Ref:    chunks.HeadSeriesRef(ref),
+					Labels: labels.FromStrings("__name__", fmt.Sprintf("metric_%d", ref)),
+				},
+			}, nil)
+			require.NoError(t, w.Log(series))
+
+			for j := 0; j < samplesCount; j++ {
+				sample := enc.Samples([]record.RefSample{
+					{
+						Ref: chunks.HeadSeriesRef(ref),
+						T:   int64(j),
+						V:   float64(j),
+					},
+				}, nil)
+				require.NoError(t, w.Log(sample))
+			}
+		}
+		if seg == 0 {
+			_, err = w.NextSegment()
+			require.NoError(t, err)
+		}
+	}
+
+	_, last, err := Segments(w.Dir())
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	metrics := NewWatcherMetrics(reg)
+	overwriteReadTimeout(t, time.Second)
+	wt := newWriteToMock(0)
+	watcher := NewWatcher(metrics, nil, nil, "progress", wt, dir, false, false, false)
+	go watcher.Start()
+
+	expected := 2 * seriesCount
+	require.Eventually(t, func() bool {
+		return wt.checkNumSeries() == expected
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// The current-segment gauge follows the watcher to the last segment.
+	require.Equal(t, float64(last), client_testutil.ToFloat64(watcher.currentSegmentMetric))
+	watcher.Stop()
+}
+
 func TestReadCheckpoint(t *testing.T) {
 	pageSize := 32 * 1024
 	const seriesCount = 10
@@ -16,7 +16,11 @@ package wlog
 
 import (
 	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/crc32"
@@ -35,6 +39,7 @@ import (
 	"github.com/prometheus/common/promslog"
 
 	"github.com/prometheus/prometheus/tsdb/fileutil"
+	"github.com/prometheus/prometheus/tsdb/record"
 )
 
 const (
@@ -214,6 +219,16 @@ type WL struct {
 	compressBuf []byte
 	zstdWriter  *zstd.Encoder
 
+	sidecar        bool // Write a JSON sidecar next to each completed segment.
+	segmentRecords int  // Records logged to the active segment.
+
+	compactStop chan struct{} // Closed to stop the background segment compaction.
+
+	aead       cipher.AEAD // Non-nil when records are encrypted at rest.
+	encryptBuf []byte
+
+	typeBytes map[record.Type]uint64 // Uncompressed bytes logged per record type.
+
 	WriteNotified WriteNotified
 
 	metrics *wlMetrics
@@ -225,6 +240,8 @@ type wlMetrics struct {
 	pageCompletions prometheus.Counter
 	truncateFail    prometheus.Counter
 	truncateTotal   prometheus.Counter
+	truncateBytes   prometheus.Counter
+	corruptions     prometheus.Counter
 	currentSegment  prometheus.Gauge
 	writesFailed    prometheus.Counter
 	walFileSize     prometheus.GaugeFunc
@@ -241,6 +258,8 @@ func (w *wlMetrics) Unregister() {
 	w.r.Unregister(w.pageCompletions)
 	w.r.Unregister(w.truncateFail)
 	w.r.Unregister(w.truncateTotal)
+	w.r.Unregister(w.truncateBytes)
+	w.r.Unregister(w.corruptions)
 	w.r.Unregister(w.currentSegment)
 	w.r.Unregister(w.writesFailed)
 	w.r.Unregister(w.walFileSize)
@@ -272,6 +291,14 @@ func newWLMetrics(w *WL, r prometheus.Registerer) *wlMetrics {
 		Name: "truncations_total",
 		Help: "Total number of write log truncations attempted.",
 	})
+	m.truncateBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "truncations_bytes_total",
+		Help: "Total number of bytes removed by write log truncations.",
+	})
+	m.corruptions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "repaired_corruptions_total",
+		Help: "Total number of write log corruptions repaired.",
+	})
 	m.currentSegment = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "segment_current",
 		Help: "Write log segment index that TSDB is currently writing to.",
@@ -299,6 +326,8 @@ func newWLMetrics(w *WL, r prometheus.Registerer) *wlMetrics {
 			m.pageCompletions,
 			m.truncateFail,
 			m.truncateTotal,
+			m.truncateBytes,
+			m.corruptions,
 			m.currentSegment,
 			m.writesFailed,
 			m.walFileSize,
@@ -344,6 +373,7 @@ func NewSize(logger *slog.Logger, reg prometheus.Registerer, dir string, segment
 		stopc:       make(chan chan struct{}),
 		compress:    compress,
 		zstdWriter:  zstdWriter,
+		typeBytes:   map[record.Type]uint64{},
 	}
 	prefix := "prometheus_tsdb_wal_"
 	if filepath.Base(dir) == WblDirName {
@@ -443,6 +473,7 @@ func (w *WL) Repair(origErr error) error {
 	if cerr.Segment < 0 {
 		return errors.New("corruption error does not specify position")
 	}
+	w.metrics.corruptions.Inc()
 	w.logger.Warn("Starting corruption repair",
 		"segment", cerr.Segment, "offset", cerr.Offset)
 
@@ -576,15 +607,20 @@ func (w *WL) nextSegment(async bool) (int, error) {
 		return 0, fmt.Errorf("create new segment file: %w", err)
 	}
 	prev := w.segment
+	prevRecords := w.segmentRecords
 	if err := w.setSegment(next); err != nil {
 		return 0, err
 	}
+	w.segmentRecords = 0
 
 	// Don't block further writes by fsyncing the last segment.
 	f := func() {
 		if err := w.fsync(prev); err != nil {
 			w.logger.Error("sync previous segment", "err", err)
 		}
+		if w.sidecar {
+			w.writeSegmentSidecar(prev, prevRecords)
+		}
 		if err := prev.Close(); err != nil {
 			w.logger.Error("close previous segment", "err", err)
 		}
@@ -597,6 +633,78 @@ func (w *WL) nextSegment(async bool) (int, error) {
 	return next.Index(), nil
 }
 
+// newAEAD returns an AES-GCM AEAD for the given key of 16, 24 or 32 bytes.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SetEncryptionKey enables AES-GCM encryption of record contents at rest
+// using the given key (16, 24 or 32 bytes). Each record is sealed with a
+// fresh nonce stored alongside the ciphertext, and flagged in the record
+// header so that readers without the key fail clearly. Records already in
+// the WAL are not rewritten. Encryption is disabled by default.
+func (w *WL) SetEncryptionKey(key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	w.mtx.Lock()
+	w.aead = aead
+	w.mtx.Unlock()
+	return nil
+}
+
+// SetSegmentSidecar toggles writing a small JSON sidecar file next to each
+// completed segment, describing its contents for debugging. It is disabled by
+// default. The sidecar is written best-effort: failures are logged but never
+// fail the WAL itself.
+func (w *WL) SetSegmentSidecar(enabled bool) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.sidecar = enabled
+}
+
+// SegmentSidecar is the JSON document describing a completed segment.
+type SegmentSidecar struct {
+	Segment     int       `json:"segment"`
+	Records     int       `json:"records"`
+	SizeBytes   int64     `json:"size_bytes"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// sidecarName builds the sidecar file path for the given segment index. The
+// suffix keeps the file from being picked up as a segment by listSegments.
+func sidecarName(dir string, i int) string {
+	return SegmentName(dir, i) + ".json"
+}
+
+// writeSegmentSidecar writes the sidecar for the given completed segment.
+// Records is the number of records logged to the segment by this WL instance.
+func (w *WL) writeSegmentSidecar(s *Segment, records int) {
+	stat, err := s.Stat()
+	if err != nil {
+		w.logger.Error("stat segment for sidecar", "segment", s.Index(), "err", err)
+		return
+	}
+	data, err := json.Marshal(SegmentSidecar{
+		Segment:     s.Index(),
+		Records:     records,
+		SizeBytes:   stat.Size(),
+		CompletedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		w.logger.Error("encode segment sidecar", "segment", s.Index(), "err", err)
+		return
+	}
+	if err := os.WriteFile(sidecarName(w.Dir(), s.Index()), data, 0o666); err != nil {
+		w.logger.Error("write segment sidecar", "segment", s.Index(), "err", err)
+	}
+}
+
 func (w *WL) setSegment(segment *Segment) error {
 	w.segment = segment
 
@@ -643,11 +751,12 @@ func (w *WL) flushPage(forceClear bool) error {
 
 // First Byte of header format:
 //
-//	[3 bits unallocated] [1 bit zstd compression flag] [1 bit snappy compression flag] [3 bit record type ]
+//	[2 bits unallocated] [1 bit encryption flag] [1 bit zstd compression flag] [1 bit snappy compression flag] [3 bit record type ]
 const (
-	snappyMask  = 1 << 3
-	zstdMask    = 1 << 4
-	recTypeMask = snappyMask - 1
+	snappyMask    = 1 << 3
+	zstdMask      = 1 << 4
+	encryptedMask = 1 << 5
+	recTypeMask   = snappyMask - 1
 )
 
 type recType uint8
@@ -706,6 +815,14 @@ func (w *WL) Log(recs ...[]byte) error {
 // - the record is bigger than the page size
 // - the current page is full.
 func (w *WL) log(rec []byte, final bool) error {
+	// Remember the record's type and uncompressed size before rec may be
+	// swapped for a compressed buffer or consumed below.
+	rtype := record.Unknown
+	if len(rec) > 0 {
+		rtype = record.Type(rec[0])
+	}
+	recLen := uint64(len(rec))
+
 	// When the last page flush failed the page will remain full.
 	// When the page is full, need to flush it before trying to add more records to it.
 	if w.page.full() {
@@ -737,6 +854,20 @@ func (w *WL) log(rec []byte, final bool) error {
 		}
 	}
 
+	// Encrypt after compression so the ciphertext does not spoil the
+	// compression ratio. The nonce is stored in front of the sealed record.
+	encrypted := false
+	if w.aead != nil && len(rec) > 0 {
+		nonce := make([]byte, w.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		w.encryptBuf = append(w.encryptBuf[:0], nonce...)
+		w.encryptBuf = w.aead.Seal(w.encryptBuf, nonce, rec, nil)
+		rec = w.encryptBuf
+		encrypted = true
+	}
+
 	// If the record is too big to fit within the active page in the current
 	// segment, terminate the active segment and advance to the next one.
 	// This ensures that records do not cross segment boundaries.
@@ -779,6 +910,9 @@ func (w *WL) log(rec []byte, final bool) error {
 				typ |= zstdMask
 			}
 		}
+		if encrypted {
+			typ |= encryptedMask
+		}
 
 		buf[0] = byte(typ)
 		crc := crc32.Checksum(part, castagnoliTable)
@@ -806,9 +940,24 @@ func (w *WL) log(rec []byte, final bool) error {
 		}
 	}
 
+	w.segmentRecords++
+	w.typeBytes[rtype] += recLen
 	return nil
 }
 
+// WrittenBytesByType returns the uncompressed bytes logged per record type
+// since the WAL was opened. Useful for analyzing what dominates WAL volume.
+func (w *WL) WrittenBytesByType() map[record.Type]uint64 {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	m := make(map[record.Type]uint64, len(w.typeBytes))
+	for t, b := range w.typeBytes {
+		m[t] = b
+	}
+	return m
+}
+
 // LastSegmentAndOffset returns the last segment number of the WAL
 // and the offset in that file upto which the segment has been filled.
 func (w *WL) LastSegmentAndOffset() (seg, offset int, err error) {
@@ -841,11 +990,204 @@ func (w *WL) Truncate(i int) (err error) {
 		if r.index >= i {
 			break
 		}
-		if err = os.Remove(filepath.Join(w.Dir(), r.name)); err != nil {
+		path := filepath.Join(w.Dir(), r.name)
+		if stat, err := os.Stat(path); err == nil {
+			w.metrics.truncateBytes.Add(float64(stat.Size()))
+		}
+		if err = os.Remove(path); err != nil {
 			return err
 		}
+		// Drop any sidecar alongside the segment, best-effort.
+		os.Remove(sidecarName(w.Dir(), r.index))
 	}
-	return nil
+	// Sync the parent directory so the removed directory entries are durable
+	// and a crash cannot resurface stale segments.
+	df, err := fileutil.OpenDir(w.Dir())
+	if err != nil {
+		return fmt.Errorf("open WAL directory: %w", err)
+	}
+	if err := df.Sync(); err != nil {
+		df.Close()
+		return fmt.Errorf("sync WAL directory: %w", err)
+	}
+	return df.Close()
+}
+
+// StartSegmentCompaction starts a background routine that periodically merges
+// runs of under-utilized segments via CompactSegments. It is opt-in and must
+// be started after the WL is created; the routine stops when the WL is closed.
+func (w *WL) StartSegmentCompaction(interval time.Duration) {
+	w.mtx.Lock()
+	if w.compactStop != nil || w.closed {
+		w.mtx.Unlock()
+		return
+	}
+	stopc := make(chan struct{})
+	w.compactStop = stopc
+	w.mtx.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopc:
+				return
+			case <-ticker.C:
+				if _, err := w.CompactSegments(); err != nil {
+					w.logger.Error("WAL segment compaction failed", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// CompactSegments merges the run of adjacent under-utilized segments at the
+// start of the WL into a single segment, reclaiming the space wasted by
+// periods that produced a lot of short segments. Only completed segments below
+// half the segment size participate. The merged records are written over the
+// last segment of the run and the preceding segments are truncated to zero
+// length rather than removed: head and agent replay walk segment indexes
+// sequentially from the last checkpoint and fail on a missing file, so the
+// index sequence must stay contiguous. Empty segments replay as no records
+// and are dropped by the next truncation. It returns the number of segments
+// emptied by the merge.
+func (w *WL) CompactSegments() (int, error) {
+	w.mtx.RLock()
+	if w.segment == nil || w.closed {
+		w.mtx.RUnlock()
+		return 0, nil
+	}
+	currentIndex := w.segment.Index()
+	w.mtx.RUnlock()
+
+	refs, err := listSegments(w.Dir())
+	if err != nil {
+		return 0, err
+	}
+
+	// Collect the run of small segments from the start that fits in a single
+	// merged segment. The active segment never participates. Zero-length
+	// segments are placeholders left by an earlier merge and carry no
+	// records; they join a run for free but do not make one worthwhile on
+	// their own.
+	var (
+		run      []segmentRef
+		total    int64
+		nonEmpty int
+	)
+	for _, r := range refs {
+		if r.index >= currentIndex {
+			break
+		}
+		stat, err := os.Stat(filepath.Join(w.Dir(), r.name))
+		if err != nil {
+			return 0, err
+		}
+		if stat.Size() >= int64(w.segmentSize)/2 || total+stat.Size() > int64(w.segmentSize) {
+			break
+		}
+		run = append(run, r)
+		total += stat.Size()
+		if stat.Size() > 0 {
+			nonEmpty++
+		}
+	}
+	if nonEmpty < 2 {
+		return 0, nil
+	}
+	first, last := run[0].index, run[len(run)-1].index
+
+	// Rewrite the records of the run into a fresh segment in a temporary
+	// directory. Completed segments are immutable, so no lock is needed here.
+	tmpDir := filepath.Join(w.Dir(), "compact.tmp")
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := func() error {
+		sr, err := NewSegmentsRangeReader(SegmentRange{Dir: w.Dir(), First: first, Last: last})
+		if err != nil {
+			return err
+		}
+		defer sr.Close()
+
+		merged, err := NewSize(w.logger, nil, tmpDir, w.segmentSize, w.compress)
+		if err != nil {
+			return err
+		}
+		merged.aead = w.aead
+
+		r := NewReader(sr)
+		r.aead = w.aead
+		for r.Next() {
+			if err := merged.Log(r.Record()); err != nil {
+				merged.Close()
+				return err
+			}
+		}
+		if err := r.Err(); err != nil {
+			merged.Close()
+			return err
+		}
+		return merged.Close()
+	}(); err != nil {
+		return 0, err
+	}
+
+	tmpRefs, err := listSegments(tmpDir)
+	if err != nil {
+		return 0, err
+	}
+	if len(tmpRefs) != 1 {
+		// The merged records unexpectedly did not fit a single segment.
+		return 0, nil
+	}
+
+	// Swap the merged segment in, coordinating with cut() and Truncate().
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.closed {
+		return 0, nil
+	}
+	for _, r := range run {
+		if _, err := os.Stat(filepath.Join(w.Dir(), r.name)); err != nil {
+			// A concurrent truncation removed part of the run, abort.
+			return 0, nil
+		}
+	}
+	// Move the merged segment over the last segment of the run before
+	// deleting anything. If we crash in between, the leading segments are
+	// replayed twice, which is recoverable, whereas deleting first could
+	// lose the whole run.
+	if err := fileutil.Rename(filepath.Join(tmpDir, tmpRefs[0].name), SegmentName(w.Dir(), last)); err != nil {
+		return 0, err
+	}
+	os.Remove(sidecarName(w.Dir(), last))
+	// Truncate the merged-away segments to zero length instead of removing
+	// them so that replay, which opens every index up to the last segment,
+	// still finds a file for each one.
+	for _, r := range run[:len(run)-1] {
+		if err := os.Truncate(filepath.Join(w.Dir(), r.name), 0); err != nil {
+			return 0, err
+		}
+		os.Remove(sidecarName(w.Dir(), r.index))
+	}
+	// Sync the parent directory so the swap is durable and a crash cannot
+	// resurface the replaced segments.
+	df, err := fileutil.OpenDir(w.Dir())
+	if err != nil {
+		return 0, fmt.Errorf("open WAL directory: %w", err)
+	}
+	if err := df.Sync(); err != nil {
+		df.Close()
+		return 0, fmt.Errorf("sync WAL directory: %w", err)
+	}
+	if err := df.Close(); err != nil {
+		return 0, err
+	}
+	return len(run) - 1, nil
 }
 
 func (w *WL) fsync(f *Segment) error {
@@ -871,6 +1213,11 @@ func (w *WL) Close() (err error) {
 		return errors.New("wlog already closed")
 	}
 
+	if w.compactStop != nil {
+		close(w.compactStop)
+		w.compactStop = nil
+	}
+
 	if w.segment == nil {
 		w.closed = true
 		return nil
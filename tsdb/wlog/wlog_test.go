@@ -17,11 +17,13 @@ package wlog
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	client_testutil "github.com/prometheus/client_golang/prometheus/testutil"
@@ -30,6 +32,7 @@ import (
 	"go.uber.org/goleak"
 
 	"github.com/prometheus/prometheus/tsdb/fileutil"
+	"github.com/prometheus/prometheus/tsdb/record"
 )
 
 func TestMain(m *testing.M) {
@@ -572,3 +575,242 @@ func TestUnregisterMetrics(t *testing.T) {
 		require.NoError(t, wl.Close())
 	}
 }
+
+func TestCorruptionAndTruncationMetrics(t *testing.T) {
+	dir := t.TempDir()
+	reg := prometheus.NewRegistry()
+
+	segSize := 3 * pageSize
+	w, err := NewSize(nil, reg, dir, segSize, CompressionNone)
+	require.NoError(t, err)
+	defer w.Close()
+
+	// Write records across multiple segments.
+	for i := 0; i < 9; i++ {
+		b := make([]byte, pageSize-recordHeaderSize)
+		b[0] = byte(i)
+		require.NoError(t, w.Log(b))
+	}
+	require.NoError(t, w.Sync())
+
+	require.Equal(t, 0.0, client_testutil.ToFloat64(w.metrics.corruptions))
+	require.Equal(t, 0.0, client_testutil.ToFloat64(w.metrics.truncateBytes))
+
+	// Corrupt the checksum of the first record in the first segment and
+	// run a repair, which must be counted as a corruption.
+	f, err := os.OpenFile(SegmentName(dir, 0), os.O_RDWR, 0o666)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0}, 4)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err := OpenReadSegment(SegmentName(dir, 0))
+	require.NoError(t, err)
+	r := NewReader(NewSegmentBufReader(s))
+	for r.Next() {
+	}
+	require.NoError(t, s.Close())
+	require.Error(t, r.Err())
+	require.NoError(t, w.Repair(r.Err()))
+
+	require.Equal(t, 1.0, client_testutil.ToFloat64(w.metrics.corruptions))
+
+	// Truncating away the repaired segment accounts the removed bytes.
+	size, err := os.Stat(SegmentName(dir, 0))
+	require.NoError(t, err)
+	require.NoError(t, w.Truncate(1))
+
+	require.Equal(t, float64(size.Size()), client_testutil.ToFloat64(w.metrics.truncateBytes))
+}
+
+func TestWrittenBytesByType(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewSize(nil, nil, dir, pageSize, CompressionNone)
+	require.NoError(t, err)
+
+	mkRec := func(rt record.Type, n int) []byte {
+		rec := make([]byte, n)
+		rec[0] = byte(rt)
+		return rec
+	}
+	require.NoError(t, w.Log(mkRec(record.Series, 100), mkRec(record.Samples, 200)))
+	require.NoError(t, w.Log(mkRec(record.Samples, 50)))
+	require.NoError(t, w.Log(mkRec(record.Tombstones, 10)))
+
+	require.Equal(t, map[record.Type]uint64{
+		record.Series:     100,
+		record.Samples:    250,
+		record.Tombstones: 10,
+	}, w.WrittenBytesByType())
+
+	require.NoError(t, w.Close())
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	for _, compress := range []CompressionType{CompressionNone, CompressionSnappy} {
+		t.Run(string(compress), func(t *testing.T) {
+			dir := t.TempDir()
+
+			w, err := NewSize(nil, nil, dir, pageSize, compress)
+			require.NoError(t, err)
+			require.NoError(t, w.SetEncryptionKey(key))
+
+			var records [][]byte
+			for i := 0; i < 10; i++ {
+				rec := make([]byte, 300)
+				for j := range rec {
+					rec[j] = byte(i*31 + j%17 + 1)
+				}
+				records = append(records, rec)
+				require.NoError(t, w.Log(rec))
+			}
+			require.NoError(t, w.Close())
+
+			// The raw segment must not contain the plaintext records.
+			raw, err := os.ReadFile(SegmentName(dir, 0))
+			require.NoError(t, err)
+			require.NotContains(t, string(raw), string(records[0]))
+
+			// Replay with the key yields the original records.
+			sr, err := NewSegmentsReader(dir)
+			require.NoError(t, err)
+			r := NewReader(sr)
+			require.NoError(t, r.SetDecryptionKey(key))
+			var got [][]byte
+			for r.Next() {
+				got = append(got, append([]byte{}, r.Record()...))
+			}
+			require.NoError(t, r.Err())
+			require.Equal(t, records, got)
+			require.NoError(t, sr.Close())
+
+			// Replay without the key fails clearly.
+			sr, err = NewSegmentsReader(dir)
+			require.NoError(t, err)
+			r = NewReader(sr)
+			require.False(t, r.Next())
+			require.ErrorContains(t, r.Err(), "no decryption key")
+			require.NoError(t, sr.Close())
+		})
+	}
+}
+
+func TestSegmentSidecar(t *testing.T) {
+	dir := t.TempDir()
+
+	segSize := 3 * pageSize
+	w, err := NewSize(nil, nil, dir, segSize, CompressionNone)
+	require.NoError(t, err)
+	w.SetSegmentSidecar(true)
+
+	// Fill the first segment and force a rotation into the second.
+	for i := 0; i < 4; i++ {
+		b := make([]byte, pageSize-recordHeaderSize)
+		b[0] = byte(i)
+		require.NoError(t, w.Log(b))
+	}
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(sidecarName(dir, 0))
+	require.NoError(t, err)
+
+	var sc SegmentSidecar
+	require.NoError(t, json.Unmarshal(data, &sc))
+	require.Equal(t, 0, sc.Segment)
+	require.Equal(t, 3, sc.Records)
+	require.Equal(t, int64(segSize), sc.SizeBytes)
+	require.False(t, sc.CompletedAt.IsZero())
+
+	// No sidecar for the still-active segment.
+	_, err = os.Stat(sidecarName(dir, 1))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestCompactSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewSize(nil, nil, dir, 8*pageSize, CompressionNone)
+	require.NoError(t, err)
+
+	// Produce several short segments, each well below the segment size.
+	var records [][]byte
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 3; j++ {
+			rec := make([]byte, 100)
+			rec[0], rec[1] = byte(i), byte(j)
+			records = append(records, rec)
+			require.NoError(t, w.Log(rec))
+		}
+		_, err := w.NextSegment()
+		require.NoError(t, err)
+	}
+
+	merged, err := w.CompactSegments()
+	require.NoError(t, err)
+	require.Equal(t, 4, merged)
+
+	// Segments 0-4 were merged into segment 4 and segment 5 is active. The
+	// merged-away segments remain as zero-length placeholders so that replay
+	// finds a file for every index.
+	first, last, err := Segments(dir)
+	require.NoError(t, err)
+	require.Equal(t, 0, first)
+	require.Equal(t, 5, last)
+	for i := 0; i < 4; i++ {
+		stat, err := os.Stat(SegmentName(dir, i))
+		require.NoError(t, err)
+		require.Zero(t, stat.Size())
+	}
+
+	// Sequential replay in the way the head does it, opening every index
+	// from the start, must succeed.
+	for i := first; i <= last; i++ {
+		s, err := OpenReadSegment(SegmentName(dir, i))
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+	}
+
+	// Replay content is unchanged.
+	sr, err := NewSegmentsReader(dir)
+	require.NoError(t, err)
+	r := NewReader(sr)
+	var got [][]byte
+	for r.Next() {
+		got = append(got, append([]byte{}, r.Record()...))
+	}
+	require.NoError(t, r.Err())
+	require.Equal(t, records, got)
+	require.NoError(t, sr.Close())
+
+	// A second pass has nothing left to merge.
+	merged, err = w.CompactSegments()
+	require.NoError(t, err)
+	require.Equal(t, 0, merged)
+
+	require.NoError(t, w.Close())
+}
+
+func TestStartSegmentCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewSize(nil, nil, dir, 8*pageSize, CompressionNone)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, w.Log([]byte{byte(i)}))
+		_, err := w.NextSegment()
+		require.NoError(t, err)
+	}
+
+	w.StartSegmentCompaction(10 * time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		stat, err := os.Stat(SegmentName(dir, 0))
+		return err == nil && stat.Size() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, w.Close())
+}
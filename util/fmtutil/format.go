@@ -17,20 +17,29 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
 
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/textparse"
 	"github.com/prometheus/prometheus/prompb"
 )
 
 const (
-	sumStr    = "_sum"
-	countStr  = "_count"
-	bucketStr = "_bucket"
+	sumStr     = "_sum"
+	countStr   = "_count"
+	bucketStr  = "_bucket"
+	totalStr   = "_total"
+	createdStr = "_created"
 )
 
 var MetricMetadataTypeValue = map[string]int32{
@@ -54,6 +63,315 @@ func MetricTextToWriteRequest(input io.Reader, labels map[string]string) (*promp
 	return MetricFamiliesToWriteRequest(mf, labels)
 }
 
+// StreamMetricsText consumes metrics text like MetricTextToWriteRequest but
+// hands the result to fn in batches of at most batchSize timeseries, so very
+// large inputs don't have to be pushed as one write request. Within each
+// batch the metadata of a metric family precedes its timeseries.
+func StreamMetricsText(input io.Reader, labels map[string]string, batchSize int, fn func(*prompb.WriteRequest) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("invalid batch size %d", batchSize)
+	}
+	var parser expfmt.TextParser
+	mf, err := parser.TextToMetricFamilies(input)
+	if err != nil {
+		return err
+	}
+
+	sortedMetricNames := make([]string, 0, len(mf))
+	for metric := range mf {
+		sortedMetricNames = append(sortedMetricNames, metric)
+	}
+	sort.Strings(sortedMetricNames)
+
+	batch := &prompb.WriteRequest{}
+	flush := func() error {
+		if len(batch.Timeseries) == 0 && len(batch.Metadata) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		batch = &prompb.WriteRequest{}
+		return nil
+	}
+
+	for _, metricName := range sortedMetricNames {
+		family := mf[metricName]
+		mtype := MetricMetadataTypeValue[family.Type.String()]
+		metadata := prompb.MetricMetadata{
+			MetricFamilyName: family.GetName(),
+			Type:             prompb.MetricMetadata_MetricType(mtype),
+			Help:             family.GetHelp(),
+		}
+
+		// Build the family's timeseries into a scratch request so a family
+		// larger than the batch size can be split across batches.
+		fam := &prompb.WriteRequest{}
+		for _, metric := range family.Metric {
+			lbls := makeLabelsMap(metric, metricName, labels)
+			if err := makeTimeseries(fam, lbls, metric); err != nil {
+				return err
+			}
+		}
+
+		if len(fam.Timeseries) == 0 {
+			batch.Metadata = append(batch.Metadata, metadata)
+			continue
+		}
+
+		for len(fam.Timeseries) > 0 {
+			if len(batch.Timeseries) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if n := len(batch.Metadata); n == 0 || batch.Metadata[n-1].MetricFamilyName != metadata.MetricFamilyName {
+				batch.Metadata = append(batch.Metadata, metadata)
+			}
+			take := batchSize - len(batch.Timeseries)
+			if take > len(fam.Timeseries) {
+				take = len(fam.Timeseries)
+			}
+			batch.Timeseries = append(batch.Timeseries, fam.Timeseries[:take]...)
+			fam.Timeseries = fam.Timeseries[take:]
+		}
+	}
+	return flush()
+}
+
+// OpenMetricsTextToWriteRequest consumes an io.Reader in OpenMetrics text format
+// and returns the data in write request format.
+func OpenMetricsTextToWriteRequest(input io.Reader, labels map[string]string) (*prompb.WriteRequest, error) {
+	mf, err := openMetricsToMetricFamilies(input)
+	if err != nil {
+		return nil, err
+	}
+	return MetricFamiliesToWriteRequest(mf, labels)
+}
+
+// openMetricsToMetricFamilies parses OpenMetrics text and reassembles the
+// suffixed series back into metric families as the protobuf exposition
+// format would describe them.
+func openMetricsToMetricFamilies(input io.Reader) (map[string]*dto.MetricFamily, error) {
+	buf, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	families := map[string]*dto.MetricFamily{}
+	// Partial summary and histogram series are grouped into a single
+	// dto.Metric, keyed by family name plus the label set without the
+	// quantile and le labels.
+	grouped := map[string]*dto.Metric{}
+
+	getFamily := func(name string) *dto.MetricFamily {
+		if family, ok := families[name]; ok {
+			return family
+		}
+		family := &dto.MetricFamily{
+			Name: proto.String(name),
+			Type: dto.MetricType_UNTYPED.Enum(),
+		}
+		families[name] = family
+		return family
+	}
+
+	p := textparse.NewOpenMetricsParser(buf, labels.NewSymbolTable())
+	var lset labels.Labels
+	for {
+		entry, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch entry {
+		case textparse.EntryType:
+			name, mtype := p.Type()
+			family := getFamily(string(name))
+			switch mtype {
+			case model.MetricTypeCounter:
+				family.Type = dto.MetricType_COUNTER.Enum()
+			case model.MetricTypeGauge:
+				family.Type = dto.MetricType_GAUGE.Enum()
+			case model.MetricTypeHistogram:
+				family.Type = dto.MetricType_HISTOGRAM.Enum()
+			case model.MetricTypeSummary:
+				family.Type = dto.MetricType_SUMMARY.Enum()
+			case model.MetricTypeUnknown:
+				family.Type = dto.MetricType_UNTYPED.Enum()
+			default:
+				return nil, fmt.Errorf("unsupported metric type %q", mtype)
+			}
+		case textparse.EntryHelp:
+			name, help := p.Help()
+			getFamily(string(name)).Help = proto.String(string(help))
+		case textparse.EntrySeries:
+			_, ts, value := p.Series()
+			p.Metric(&lset)
+			name := lset.Get(model.MetricNameLabel)
+			family, suffix := findOpenMetricsFamily(families, name)
+			if family == nil {
+				family = getFamily(name)
+			}
+			if err := addOpenMetricsSample(family, suffix, grouped, lset, ts, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// OpenMetrics counter samples carry the _total suffix while the family
+	// name does not. Rename counter families so the resulting write request
+	// matches the Prometheus text format, taking care not to double-append
+	// the suffix when the family name already carries it.
+	for name, family := range families {
+		if family.GetType() != dto.MetricType_COUNTER || strings.HasSuffix(name, totalStr) {
+			continue
+		}
+		delete(families, name)
+		family.Name = proto.String(name + totalStr)
+		families[family.GetName()] = family
+	}
+	return families, nil
+}
+
+// findOpenMetricsFamily resolves a series name to its metric family, stripping
+// the OpenMetrics suffix when it is consistent with the family type.
+func findOpenMetricsFamily(families map[string]*dto.MetricFamily, name string) (*dto.MetricFamily, string) {
+	if family, ok := families[name]; ok {
+		return family, ""
+	}
+	for _, suffix := range []string{totalStr, createdStr, sumStr, countStr, bucketStr} {
+		base := strings.TrimSuffix(name, suffix)
+		if base == name {
+			continue
+		}
+		family, ok := families[base]
+		if !ok {
+			continue
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			if suffix == totalStr || suffix == createdStr {
+				return family, suffix
+			}
+		case dto.MetricType_SUMMARY:
+			if suffix == sumStr || suffix == countStr || suffix == createdStr {
+				return family, suffix
+			}
+		case dto.MetricType_HISTOGRAM:
+			if suffix == bucketStr || suffix == sumStr || suffix == countStr || suffix == createdStr {
+				return family, suffix
+			}
+		}
+	}
+	return nil, ""
+}
+
+func addOpenMetricsSample(family *dto.MetricFamily, suffix string, grouped map[string]*dto.Metric, lset labels.Labels, ts *int64, value float64) error {
+	if suffix == createdStr {
+		// _created series have no equivalent in the protobuf exposition
+		// format, drop them rather than emitting a bogus sample.
+		return nil
+	}
+
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		m := newOpenMetricsMetric(lset, ts)
+		m.Counter = &dto.Counter{Value: proto.Float64(value)}
+		family.Metric = append(family.Metric, m)
+	case dto.MetricType_GAUGE:
+		m := newOpenMetricsMetric(lset, ts)
+		m.Gauge = &dto.Gauge{Value: proto.Float64(value)}
+		family.Metric = append(family.Metric, m)
+	case dto.MetricType_SUMMARY:
+		m := groupedOpenMetricsMetric(family, grouped, lset, ts)
+		if m.Summary == nil {
+			m.Summary = &dto.Summary{}
+		}
+		switch suffix {
+		case sumStr:
+			m.Summary.SampleSum = proto.Float64(value)
+		case countStr:
+			m.Summary.SampleCount = proto.Uint64(uint64(value))
+		default:
+			q, err := strconv.ParseFloat(lset.Get(model.QuantileLabel), 64)
+			if err != nil {
+				return fmt.Errorf("invalid quantile label for summary %q: %w", family.GetName(), err)
+			}
+			m.Summary.Quantile = append(m.Summary.Quantile, &dto.Quantile{
+				Quantile: proto.Float64(q),
+				Value:    proto.Float64(value),
+			})
+		}
+	case dto.MetricType_HISTOGRAM:
+		m := groupedOpenMetricsMetric(family, grouped, lset, ts)
+		if m.Histogram == nil {
+			m.Histogram = &dto.Histogram{}
+		}
+		switch suffix {
+		case sumStr:
+			m.Histogram.SampleSum = proto.Float64(value)
+		case countStr:
+			m.Histogram.SampleCount = proto.Uint64(uint64(value))
+		case bucketStr:
+			ub, err := strconv.ParseFloat(lset.Get(model.BucketLabel), 64)
+			if err != nil {
+				return fmt.Errorf("invalid le label for histogram %q: %w", family.GetName(), err)
+			}
+			m.Histogram.Bucket = append(m.Histogram.Bucket, &dto.Bucket{
+				UpperBound:      proto.Float64(ub),
+				CumulativeCount: proto.Uint64(uint64(value)),
+			})
+		default:
+			return fmt.Errorf("unexpected series %q for histogram %q", lset.Get(model.MetricNameLabel), family.GetName())
+		}
+	default:
+		m := newOpenMetricsMetric(lset, ts)
+		m.Untyped = &dto.Untyped{Value: proto.Float64(value)}
+		family.Metric = append(family.Metric, m)
+	}
+	return nil
+}
+
+func newOpenMetricsMetric(lset labels.Labels, ts *int64) *dto.Metric {
+	m := &dto.Metric{Label: openMetricsLabelPairs(lset)}
+	if ts != nil {
+		m.TimestampMs = proto.Int64(*ts)
+	}
+	return m
+}
+
+func groupedOpenMetricsMetric(family *dto.MetricFamily, grouped map[string]*dto.Metric, lset labels.Labels, ts *int64) *dto.Metric {
+	key := family.GetName() + labels.NewBuilder(lset).Del(model.MetricNameLabel, model.QuantileLabel, model.BucketLabel).Labels().String()
+	m, ok := grouped[key]
+	if !ok {
+		m = &dto.Metric{Label: openMetricsLabelPairs(lset, model.QuantileLabel, model.BucketLabel)}
+		grouped[key] = m
+		family.Metric = append(family.Metric, m)
+	}
+	if ts != nil {
+		m.TimestampMs = proto.Int64(*ts)
+	}
+	return m
+}
+
+func openMetricsLabelPairs(lset labels.Labels, drop ...string) []*dto.LabelPair {
+	var pairs []*dto.LabelPair
+	lset.Range(func(l labels.Label) {
+		if l.Name == model.MetricNameLabel || slices.Contains(drop, l.Name) {
+			return
+		}
+		pairs = append(pairs, &dto.LabelPair{
+			Name:  proto.String(l.Name),
+			Value: proto.String(l.Value),
+		})
+	})
+	return pairs
+}
+
 // MetricFamiliesToWriteRequest convert metric family to a writerequest.
 func MetricFamiliesToWriteRequest(mf map[string]*dto.MetricFamily, extraLabels map[string]string) (*prompb.WriteRequest, error) {
 	wr := &prompb.WriteRequest{}
@@ -111,6 +429,7 @@ func makeTimeseries(wr *prompb.WriteRequest, labels map[string]string, m *dto.Me
 		toTimeseries(wr, labels, timestamp, m.GetGauge().GetValue())
 	case m.Counter != nil:
 		toTimeseries(wr, labels, timestamp, m.GetCounter().GetValue())
+		addExemplar(wr, m.GetCounter().GetExemplar(), timestamp)
 	case m.Summary != nil:
 		metricName := labels[model.MetricNameLabel]
 		// Preserve metric name order with first quantile labels timeseries then sum suffix timeseries and finally count suffix timeseries
@@ -132,6 +451,10 @@ func makeTimeseries(wr *prompb.WriteRequest, labels map[string]string, m *dto.Me
 		labels[model.MetricNameLabel] = metricName + countStr
 		toTimeseries(wr, labels, timestamp, float64(m.GetSummary().GetSampleCount()))
 
+	case m.Histogram != nil && isNativeHistogram(m.GetHistogram()):
+		// Native histograms are sent as a single series carrying a histogram
+		// sample instead of the classic bucket, sum and count series.
+		toNativeHistogramTimeseries(wr, labels, timestamp, m.GetHistogram())
 	case m.Histogram != nil:
 		metricName := labels[model.MetricNameLabel]
 		// Preserve metric name order with first bucket suffix timeseries then sum suffix timeseries and finally count suffix timeseries
@@ -144,6 +467,7 @@ func makeTimeseries(wr *prompb.WriteRequest, labels map[string]string, m *dto.Me
 			bucketLabels[model.MetricNameLabel] = metricName + bucketStr
 			bucketLabels[model.BucketLabel] = fmt.Sprint(b.GetUpperBound())
 			toTimeseries(wr, bucketLabels, timestamp, float64(b.GetCumulativeCount()))
+			addExemplar(wr, b.GetExemplar(), timestamp)
 		}
 		// Overwrite label model.MetricNameLabel for count and sum metrics
 		// Add Histogram sum timeseries
@@ -161,6 +485,80 @@ func makeTimeseries(wr *prompb.WriteRequest, labels map[string]string, m *dto.Me
 	return err
 }
 
+// isNativeHistogram reports whether the histogram carries native (sparse)
+// histogram fields rather than only classic buckets.
+func isNativeHistogram(h *dto.Histogram) bool {
+	return len(h.GetPositiveSpan()) > 0 ||
+		len(h.GetNegativeSpan()) > 0 ||
+		h.GetZeroThreshold() > 0 ||
+		h.GetZeroCount() > 0
+}
+
+func toNativeHistogramTimeseries(wr *prompb.WriteRequest, labels map[string]string, timestamp int64, h *dto.Histogram) {
+	var ts prompb.TimeSeries
+	ts.Labels = makeLabels(labels)
+
+	if h.GetSampleCountFloat() > 0 || h.GetZeroCountFloat() > 0 {
+		// It is a float histogram.
+		fh := &histogram.FloatHistogram{
+			Count:           h.GetSampleCountFloat(),
+			Sum:             h.GetSampleSum(),
+			ZeroThreshold:   h.GetZeroThreshold(),
+			ZeroCount:       h.GetZeroCountFloat(),
+			Schema:          h.GetSchema(),
+			PositiveSpans:   spansFromProto(h.GetPositiveSpan()),
+			PositiveBuckets: h.GetPositiveCount(),
+			NegativeSpans:   spansFromProto(h.GetNegativeSpan()),
+			NegativeBuckets: h.GetNegativeCount(),
+		}
+		ts.Histograms = []prompb.Histogram{prompb.FromFloatHistogram(timestamp, fh)}
+	} else {
+		sh := &histogram.Histogram{
+			Count:           h.GetSampleCount(),
+			Sum:             h.GetSampleSum(),
+			ZeroThreshold:   h.GetZeroThreshold(),
+			ZeroCount:       h.GetZeroCount(),
+			Schema:          h.GetSchema(),
+			PositiveSpans:   spansFromProto(h.GetPositiveSpan()),
+			PositiveBuckets: h.GetPositiveDelta(),
+			NegativeSpans:   spansFromProto(h.GetNegativeSpan()),
+			NegativeBuckets: h.GetNegativeDelta(),
+		}
+		ts.Histograms = []prompb.Histogram{prompb.FromIntHistogram(timestamp, sh)}
+	}
+	wr.Timeseries = append(wr.Timeseries, ts)
+}
+
+func spansFromProto(spans []*dto.BucketSpan) []histogram.Span {
+	out := make([]histogram.Span, len(spans))
+	for i, span := range spans {
+		out[i].Offset = span.GetOffset()
+		out[i].Length = span.GetLength()
+	}
+	return out
+}
+
+// addExemplar attaches the exemplar to the timeseries appended last, falling
+// back to the sample timestamp when the exemplar carries none.
+func addExemplar(wr *prompb.WriteRequest, e *dto.Exemplar, timestamp int64) {
+	if e == nil {
+		return
+	}
+	exemplarLabels := make(map[string]string, len(e.Label))
+	for _, label := range e.Label {
+		exemplarLabels[label.GetName()] = label.GetValue()
+	}
+	if e.Timestamp != nil {
+		timestamp = e.Timestamp.AsTime().UnixMilli()
+	}
+	ts := &wr.Timeseries[len(wr.Timeseries)-1]
+	ts.Exemplars = append(ts.Exemplars, prompb.Exemplar{
+		Labels:    makeLabels(exemplarLabels),
+		Value:     e.GetValue(),
+		Timestamp: timestamp,
+	})
+}
+
 func makeLabels(labelsMap map[string]string) []prompb.Label {
 	// build labels name list
 	sortedLabelNames := make([]string, 0, len(labelsMap))
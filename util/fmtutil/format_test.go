@@ -15,10 +15,16 @@ package fmtutil
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/gogo/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/prompb"
 )
 
@@ -207,6 +213,303 @@ func TestParseAndPushMetricsTextAndFormat(t *testing.T) {
 	require.Equal(t, expected, writeRequestFixture)
 }
 
+var openMetricsWriteRequestFixture = &prompb.WriteRequest{
+	Metadata: []prompb.MetricMetadata{
+		{
+			MetricFamilyName: "http_request_duration_seconds",
+			Type:             3,
+			Help:             "A histogram of the request duration.",
+		},
+		{
+			MetricFamilyName: "http_requests_total",
+			Type:             1,
+			Help:             "The total number of HTTP requests.",
+		},
+		{
+			MetricFamilyName: "rpc_duration_seconds",
+			Type:             5,
+			Help:             "A summary of the RPC duration in seconds.",
+		},
+		{
+			MetricFamilyName: "test_metric1",
+			Type:             2,
+			Help:             "This is a test metric.",
+		},
+	},
+	Timeseries: []prompb.TimeSeries{
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "http_request_duration_seconds_bucket"},
+				{Name: "job", Value: "promtool"},
+				{Name: "le", Value: "0.1"},
+			},
+			Samples: []prompb.Sample{{Value: 33444, Timestamp: 1000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "http_request_duration_seconds_bucket"},
+				{Name: "job", Value: "promtool"},
+				{Name: "le", Value: "+Inf"},
+			},
+			Samples: []prompb.Sample{{Value: 144320, Timestamp: 1000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "http_request_duration_seconds_sum"},
+				{Name: "job", Value: "promtool"},
+			},
+			Samples: []prompb.Sample{{Value: 53423, Timestamp: 1000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "http_request_duration_seconds_count"},
+				{Name: "job", Value: "promtool"},
+			},
+			Samples: []prompb.Sample{{Value: 144320, Timestamp: 1000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "http_requests_total"},
+				{Name: "code", Value: "200"},
+				{Name: "job", Value: "promtool"},
+				{Name: "method", Value: "post"},
+			},
+			Samples: []prompb.Sample{{Value: 1027, Timestamp: 1395066363000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "http_requests_total"},
+				{Name: "code", Value: "400"},
+				{Name: "job", Value: "promtool"},
+				{Name: "method", Value: "post"},
+			},
+			Samples: []prompb.Sample{{Value: 3, Timestamp: 1395066363000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "rpc_duration_seconds"},
+				{Name: "job", Value: "promtool"},
+				{Name: "quantile", Value: "0.01"},
+			},
+			Samples: []prompb.Sample{{Value: 3102, Timestamp: 1000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "rpc_duration_seconds"},
+				{Name: "job", Value: "promtool"},
+				{Name: "quantile", Value: "0.99"},
+			},
+			Samples: []prompb.Sample{{Value: 76656, Timestamp: 1000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "rpc_duration_seconds_sum"},
+				{Name: "job", Value: "promtool"},
+			},
+			Samples: []prompb.Sample{{Value: 1.7560473e+07, Timestamp: 1000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "rpc_duration_seconds_count"},
+				{Name: "job", Value: "promtool"},
+			},
+			Samples: []prompb.Sample{{Value: 2693, Timestamp: 1000}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "test_metric1"},
+				{Name: "b", Value: "c"},
+				{Name: "baz", Value: "qux"},
+				{Name: "job", Value: "promtool"},
+			},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+		},
+	},
+}
+
+func TestParseAndPushOpenMetricsTextAndFormat(t *testing.T) {
+	input := bytes.NewReader([]byte(`# HELP http_request_duration_seconds A histogram of the request duration.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.1"} 33444 1
+http_request_duration_seconds_bucket{le="+Inf"} 144320 1
+http_request_duration_seconds_sum 53423 1
+http_request_duration_seconds_count 144320 1
+# HELP http_requests The total number of HTTP requests.
+# TYPE http_requests counter
+http_requests_total{method="post",code="200"} 1027 1395066363
+http_requests_total{method="post",code="400"} 3 1395066363
+http_requests_created{method="post",code="200"} 1395066300 1395066363
+# HELP rpc_duration_seconds A summary of the RPC duration in seconds.
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.01"} 3102 1
+rpc_duration_seconds{quantile="0.99"} 76656 1
+rpc_duration_seconds_sum 1.7560473e+07 1
+rpc_duration_seconds_count 2693 1
+# HELP test_metric1 This is a test metric.
+# TYPE test_metric1 gauge
+test_metric1{b="c",baz="qux"} 1 1
+# EOF
+`))
+	labels := map[string]string{"job": "promtool"}
+
+	actual, err := OpenMetricsTextToWriteRequest(input, labels)
+	require.NoError(t, err)
+
+	require.Equal(t, openMetricsWriteRequestFixture, actual)
+}
+
+func TestOpenMetricsTextToWriteRequestErrorParsingMetricType(t *testing.T) {
+	input := bytes.NewReader([]byte(`# HELP node_info node info summary.
+# TYPE node_info info
+node_info{test="summary"} 1
+# EOF
+`))
+	labels := map[string]string{"job": "promtool"}
+
+	_, err := OpenMetricsTextToWriteRequest(input, labels)
+	require.Equal(t, "unsupported metric type \"info\"", err.Error())
+}
+
+func TestMetricFamiliesToWriteRequestExemplars(t *testing.T) {
+	mf := map[string]*dto.MetricFamily{
+		"http_request_duration_seconds": {
+			Name: proto.String("http_request_duration_seconds"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{{
+				TimestampMs: proto.Int64(1),
+				Histogram: &dto.Histogram{
+					SampleSum:   proto.Float64(53423),
+					SampleCount: proto.Uint64(144320),
+					Bucket: []*dto.Bucket{{
+						UpperBound:      proto.Float64(0.1),
+						CumulativeCount: proto.Uint64(33444),
+						Exemplar: &dto.Exemplar{
+							Label: []*dto.LabelPair{
+								{Name: proto.String("trace_id"), Value: proto.String("def456")},
+							},
+							Value: proto.Float64(0.05),
+						},
+					}},
+				},
+			}},
+		},
+		"http_requests_total": {
+			Name: proto.String("http_requests_total"),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{{
+				TimestampMs: proto.Int64(1),
+				Counter: &dto.Counter{
+					Value: proto.Float64(1027),
+					Exemplar: &dto.Exemplar{
+						Label: []*dto.LabelPair{
+							{Name: proto.String("trace_id"), Value: proto.String("abc123")},
+						},
+						Value:     proto.Float64(1),
+						Timestamp: timestamppb.New(time.UnixMilli(1395066363000)),
+					},
+				},
+			}},
+		},
+	}
+	labels := map[string]string{"job": "promtool"}
+
+	actual, err := MetricFamiliesToWriteRequest(mf, labels)
+	require.NoError(t, err)
+	require.Len(t, actual.Timeseries, 4)
+
+	// The bucket exemplar carries no timestamp, so the sample timestamp is used.
+	require.Equal(t, []prompb.Exemplar{{
+		Labels:    []prompb.Label{{Name: "trace_id", Value: "def456"}},
+		Value:     0.05,
+		Timestamp: 1,
+	}}, actual.Timeseries[0].Exemplars)
+	require.Empty(t, actual.Timeseries[1].Exemplars)
+	require.Empty(t, actual.Timeseries[2].Exemplars)
+	require.Equal(t, []prompb.Exemplar{{
+		Labels:    []prompb.Label{{Name: "trace_id", Value: "abc123"}},
+		Value:     1,
+		Timestamp: 1395066363000,
+	}}, actual.Timeseries[3].Exemplars)
+}
+
+func TestMetricFamiliesToWriteRequestNativeHistogram(t *testing.T) {
+	mf := map[string]*dto.MetricFamily{
+		"http_request_duration_seconds": {
+			Name: proto.String("http_request_duration_seconds"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{{
+				TimestampMs: proto.Int64(1),
+				Histogram: &dto.Histogram{
+					SampleCount:   proto.Uint64(6),
+					SampleSum:     proto.Float64(13.4),
+					Schema:        proto.Int32(1),
+					ZeroThreshold: proto.Float64(0.001),
+					ZeroCount:     proto.Uint64(1),
+					PositiveSpan: []*dto.BucketSpan{
+						{Offset: proto.Int32(0), Length: proto.Uint32(2)},
+					},
+					PositiveDelta: []int64{3, -1},
+				},
+			}},
+		},
+	}
+	labels := map[string]string{"job": "promtool"}
+
+	actual, err := MetricFamiliesToWriteRequest(mf, labels)
+	require.NoError(t, err)
+
+	// A native histogram is sent as a single series with a histogram sample,
+	// there must be no classic bucket, sum or count series.
+	require.Len(t, actual.Timeseries, 1)
+	require.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "http_request_duration_seconds"},
+		{Name: "job", Value: "promtool"},
+	}, actual.Timeseries[0].Labels)
+	require.Empty(t, actual.Timeseries[0].Samples)
+	require.Equal(t, []prompb.Histogram{prompb.FromIntHistogram(1, &histogram.Histogram{
+		Count:           6,
+		Sum:             13.4,
+		Schema:          1,
+		ZeroThreshold:   0.001,
+		ZeroCount:       1,
+		PositiveSpans:   []histogram.Span{{Offset: 0, Length: 2}},
+		PositiveBuckets: []int64{3, -1},
+	})}, actual.Timeseries[0].Histograms)
+}
+
+func TestStreamMetricsText(t *testing.T) {
+	var input bytes.Buffer
+	for f := 0; f < 5; f++ {
+		fmt.Fprintf(&input, "# HELP metric_%d A metric.\n# TYPE metric_%d gauge\n", f, f)
+		for i := 0; i < 7; i++ {
+			fmt.Fprintf(&input, "metric_%d{instance=\"%d\"} 1 1395066363000\n", f, i)
+		}
+	}
+
+	var batches []*prompb.WriteRequest
+	err := StreamMetricsText(&input, map[string]string{"job": "promtool"}, 10, func(wr *prompb.WriteRequest) error {
+		batches = append(batches, wr)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, batches, 4)
+
+	total := 0
+	for _, b := range batches {
+		require.LessOrEqual(t, len(b.Timeseries), 10)
+		total += len(b.Timeseries)
+	}
+	require.Equal(t, 35, total)
+
+	// The first batch holds metric_0 and metric_1 with metadata preceding the
+	// series of each family; the split family's metadata repeats in batch two.
+	require.Equal(t, "metric_0", batches[0].Metadata[0].MetricFamilyName)
+	require.Equal(t, "metric_1", batches[0].Metadata[1].MetricFamilyName)
+	require.Equal(t, "metric_1", batches[1].Metadata[0].MetricFamilyName)
+
+	require.EqualError(t, StreamMetricsText(&input, nil, 0, func(*prompb.WriteRequest) error { return nil }), "invalid batch size 0")
+}
+
 func TestMetricTextToWriteRequestErrorParsingFloatValue(t *testing.T) {
 	input := bytes.NewReader([]byte(`
 	# HELP http_requests_total The total number of HTTP requests.
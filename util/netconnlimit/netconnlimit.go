@@ -95,3 +95,85 @@ func (l *sharedLimitListenerConn) Close() error {
 	l.releaseOnce.Do(l.release)
 	return err
 }
+
+// NewSharedPerHostCounter creates a counter of simultaneous connections per
+// remote host that can be shared across multiple listeners. A limit of 0 or
+// less disables the per-host limit.
+func NewSharedPerHostCounter(n int) *PerHostCounter {
+	return &PerHostCounter{max: n, counts: map[string]int{}}
+}
+
+// PerHostCounter tracks simultaneous connections per remote host.
+type PerHostCounter struct {
+	max    int
+	mtx    sync.Mutex
+	counts map[string]int
+}
+
+func (c *PerHostCounter) acquire(host string) bool {
+	if c.max <= 0 {
+		return true
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.counts[host] >= c.max {
+		return false
+	}
+	c.counts[host]++
+	return true
+}
+
+func (c *PerHostCounter) release(host string) {
+	if c.max <= 0 {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.counts[host] <= 1 {
+		delete(c.counts, host)
+	} else {
+		c.counts[host]--
+	}
+}
+
+// PerHostLimitListener returns a listener that closes new connections from a
+// remote host that is already at its quota in the provided shared counter,
+// while connections from other hosts are unaffected.
+func PerHostLimitListener(l net.Listener, c *PerHostCounter) net.Listener {
+	return &perHostLimitListener{Listener: l, counter: c}
+}
+
+type perHostLimitListener struct {
+	net.Listener
+	counter *PerHostCounter
+}
+
+func (l *perHostLimitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+		if err != nil {
+			host = c.RemoteAddr().String()
+		}
+		if !l.counter.acquire(host) {
+			c.Close()
+			continue
+		}
+		return &perHostLimitListenerConn{Conn: c, release: func() { l.counter.release(host) }}, nil
+	}
+}
+
+type perHostLimitListenerConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *perHostLimitListenerConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
@@ -122,3 +122,73 @@ func TestSharedLimitListenerClose(t *testing.T) {
 		conn.Close()
 	}
 }
+
+// fakeAddrConn wraps a pipe connection with a fixed remote address.
+type fakeAddrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *fakeAddrConn) RemoteAddr() net.Addr { return c.remote }
+
+// fakeListener hands out the connections pushed into its channel.
+type fakeListener struct {
+	conns chan net.Conn
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) { return <-l.conns, nil }
+func (l *fakeListener) Close() error              { return nil }
+func (l *fakeListener) Addr() net.Addr            { return &net.TCPAddr{IP: net.IPv4zero} }
+
+func TestPerHostLimitListener(t *testing.T) {
+	counter := NewSharedPerHostCounter(2)
+	conns := make(chan net.Conn, 8)
+	listener := PerHostLimitListener(&fakeListener{conns: conns}, counter)
+
+	mkConn := func(ip string) (server, client net.Conn) {
+		server, client = net.Pipe()
+		addr := &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345}
+		return &fakeAddrConn{Conn: server, remote: addr}, client
+	}
+
+	remoteHost := func(c net.Conn) string {
+		host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+		require.NoError(t, err)
+		return host
+	}
+
+	// Two connections from the first IP fill its quota.
+	s1, _ := mkConn("10.0.0.1")
+	s2, _ := mkConn("10.0.0.1")
+	conns <- s1
+	conns <- s2
+	c1, err := listener.Accept()
+	require.NoError(t, err)
+	c2, err := listener.Accept()
+	require.NoError(t, err)
+
+	// A third connection from the same IP is rejected and closed, while a
+	// connection from another IP is accepted right after.
+	rejected, rejectedPeer := mkConn("10.0.0.1")
+	other, _ := mkConn("10.0.0.2")
+	conns <- rejected
+	conns <- other
+	c3, err := listener.Accept()
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.2", remoteHost(c3))
+
+	_, err = rejectedPeer.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+
+	// Closing a connection frees quota for its IP again.
+	require.NoError(t, c1.Close())
+	s4, _ := mkConn("10.0.0.1")
+	conns <- s4
+	c4, err := listener.Accept()
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1", remoteHost(c4))
+
+	for _, c := range []net.Conn{c2, c3, c4} {
+		require.NoError(t, c.Close())
+	}
+}
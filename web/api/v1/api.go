@@ -217,6 +217,7 @@ type API struct {
 	statsRenderer       StatsRenderer
 	notificationsGetter func() []notifications.Notification
 	notificationsSub    func() (<-chan notifications.Notification, func(), bool)
+	alignStepsToEpoch   bool
 
 	remoteWriteHandler http.Handler
 	remoteReadHandler  http.Handler
@@ -258,6 +259,7 @@ func NewAPI(
 	rwEnabled bool,
 	acceptRemoteWriteProtoMsgs []config.RemoteWriteProtoMsg,
 	otlpEnabled bool,
+	alignStepsToEpoch bool,
 ) *API {
 	a := &API{
 		QueryEngine:       qe,
@@ -286,6 +288,7 @@ func NewAPI(
 		statsRenderer:       DefaultStatsRenderer,
 		notificationsGetter: notificationsGetter,
 		notificationsSub:    notificationsSub,
+		alignStepsToEpoch:   alignStepsToEpoch,
 
 		remoteReadHandler: remote.NewReadHandler(logger, registerer, q, configFunc, remoteReadSampleLimit, remoteReadConcurrencyLimit, remoteReadMaxBytesInFrame),
 	}
@@ -522,6 +525,12 @@ func extractQueryOpts(r *http.Request) (promql.QueryOpts, error) {
 	return promql.NewPrometheusQueryOpts(r.FormValue("stats") == "all", duration), nil
 }
 
+// alignToStep snaps t down to the nearest multiple of step relative to the
+// Unix epoch.
+func alignToStep(t time.Time, step time.Duration) time.Time {
+	return t.Add(-time.Duration(t.UnixNano() % int64(step)))
+}
+
 func (api *API) queryRange(r *http.Request) (result apiFuncResult) {
 	start, err := parseTime(r.FormValue("start"))
 	if err != nil {
@@ -544,6 +553,11 @@ func (api *API) queryRange(r *http.Request) (result apiFuncResult) {
 		return invalidParamError(errors.New("zero or negative query resolution step widths are not accepted. Try a positive integer"), "step")
 	}
 
+	if api.alignStepsToEpoch {
+		start = alignToStep(start, step)
+		end = alignToStep(end, step)
+	}
+
 	// For safety, limit the number of returned points per timeseries.
 	// This is sufficient for 60s resolution for a week or 1h resolution for a year.
 	if end.Sub(start)/step > 11000 {
@@ -1832,6 +1846,12 @@ func (api *API) otlpWrite(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// SeriesDeleteStats is the result of a delete_series dry run, reporting how
+// many series the selectors matched without writing any tombstones.
+type SeriesDeleteStats struct {
+	MatchedSeries int64 `json:"matchedSeries"`
+}
+
 func (api *API) deleteSeries(r *http.Request) apiFuncResult {
 	if !api.enableAdmin {
 		return apiFuncResult{nil, &apiError{errorUnavailable, errors.New("admin APIs disabled")}, nil, nil}
@@ -1843,6 +1863,15 @@ func (api *API) deleteSeries(r *http.Request) apiFuncResult {
 		return apiFuncResult{nil, &apiError{errorBadData, errors.New("no match[] parameter provided")}, nil, nil}
 	}
 
+	var dryRun bool
+	if v := r.FormValue("dry_run"); v != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			return invalidParamError(err, "dry_run")
+		}
+	}
+
 	start, err := parseTimeParam(r, "start", MinTime)
 	if err != nil {
 		return invalidParamError(err, "start")
@@ -1852,11 +1881,20 @@ func (api *API) deleteSeries(r *http.Request) apiFuncResult {
 		return invalidParamError(err, "end")
 	}
 
+	matcherSets := make([][]*labels.Matcher, 0, len(r.Form["match[]"]))
 	for _, s := range r.Form["match[]"] {
 		matchers, err := parser.ParseMetricSelector(s)
 		if err != nil {
 			return invalidParamError(err, "match[]")
 		}
+		matcherSets = append(matcherSets, matchers)
+	}
+
+	if dryRun {
+		return api.countDeleteSeries(r.Context(), timestamp.FromTime(start), timestamp.FromTime(end), matcherSets)
+	}
+
+	for _, matchers := range matcherSets {
 		if err := api.db.Delete(r.Context(), timestamp.FromTime(start), timestamp.FromTime(end), matchers...); err != nil {
 			return apiFuncResult{nil, &apiError{errorInternal, err}, nil, nil}
 		}
@@ -1865,6 +1903,37 @@ func (api *API) deleteSeries(r *http.Request) apiFuncResult {
 	return apiFuncResult{nil, nil, nil, nil}
 }
 
+// countDeleteSeries counts the series the given selectors match within
+// [mint, maxt] without mutating anything, for delete_series dry runs.
+func (api *API) countDeleteSeries(ctx context.Context, mint, maxt int64, matcherSets [][]*labels.Matcher) apiFuncResult {
+	q, err := api.Queryable.Querier(mint, maxt)
+	if err != nil {
+		return apiFuncResult{nil, returnAPIError(err), nil, nil}
+	}
+	defer q.Close()
+
+	var set storage.SeriesSet
+	if len(matcherSets) > 1 {
+		var sets []storage.SeriesSet
+		for _, mset := range matcherSets {
+			// We need sorted select results to merge (deduplicate) the series sets later.
+			sets = append(sets, q.Select(ctx, true, nil, mset...))
+		}
+		set = storage.NewMergeSeriesSet(sets, 0, storage.ChainedSeriesMerge)
+	} else {
+		set = q.Select(ctx, false, nil, matcherSets[0]...)
+	}
+
+	var stats SeriesDeleteStats
+	for set.Next() {
+		stats.MatchedSeries++
+	}
+	if err := set.Err(); err != nil {
+		return apiFuncResult{nil, returnAPIError(err), set.Warnings(), nil}
+	}
+	return apiFuncResult{stats, nil, set.Warnings(), nil}
+}
+
 func (api *API) snapshot(r *http.Request) apiFuncResult {
 	if !api.enableAdmin {
 		return apiFuncResult{nil, &apiError{errorUnavailable, errors.New("admin APIs disabled")}, nil, nil}
@@ -1894,9 +1963,17 @@ func (api *API) snapshot(r *http.Request) apiFuncResult {
 		return apiFuncResult{nil, &apiError{errorInternal, fmt.Errorf("create snapshot: %w", err)}, nil, nil}
 	}
 
+	// Spare clients from reconstructing the on-disk location from the base
+	// name and knowledge of the TSDB layout.
+	absPath, err := filepath.Abs(dir)
+	if err != nil {
+		absPath = dir
+	}
+
 	return apiFuncResult{struct {
-		Name string `json:"name"`
-	}{name}, nil, nil, nil}
+		Name         string `json:"name"`
+		AbsolutePath string `json:"absolutePath"`
+	}{name, absPath}, nil, nil, nil}
 }
 
 func (api *API) cleanTombstones(*http.Request) apiFuncResult {
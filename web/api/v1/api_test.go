@@ -3571,6 +3571,66 @@ func (f *fakeDB) WALReplayStatus() (tsdb.WALReplayStatus, error) {
 	return tsdb.WALReplayStatus{}, nil
 }
 
+type countingDeleteDB struct {
+	fakeDB
+	deletes int
+}
+
+func (f *countingDeleteDB) Delete(context.Context, int64, int64, ...*labels.Matcher) error {
+	f.deletes++
+	return nil
+}
+
+func TestDeleteSeriesDryRun(t *testing.T) {
+	storage := promqltest.LoadedStorage(t, `
+		load 1m
+			test_metric1{foo="bar"} 0+100x100
+			test_metric1{foo="boo"} 1+0x100
+			test_metric2{foo="boo"} 1+0x100
+	`)
+	t.Cleanup(func() { storage.Close() })
+
+	db := &countingDeleteDB{}
+	api := &API{
+		db:          db,
+		Queryable:   storage,
+		ready:       func(f http.HandlerFunc) http.HandlerFunc { return f },
+		enableAdmin: true,
+	}
+
+	for _, tc := range []struct {
+		values  url.Values
+		matched int64
+	}{
+		{values: url.Values{"match[]": {"test_metric1"}, "dry_run": {"true"}}, matched: 2},
+		{values: url.Values{"match[]": {"test_metric1", "test_metric2"}, "dry_run": {"true"}}, matched: 3},
+		{values: url.Values{"match[]": {`{foo="boo"}`, "test_metric2"}, "dry_run": {"true"}}, matched: 2},
+		{values: url.Values{"match[]": {"does_not_exist"}, "dry_run": {"1"}}, matched: 0},
+	} {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("?%s", tc.values.Encode()), nil)
+		require.NoError(t, err)
+
+		res := api.deleteSeries(req)
+		assertAPIError(t, res.err, errorNone)
+		require.Equal(t, SeriesDeleteStats{MatchedSeries: tc.matched}, res.data, "values %v", tc.values)
+		require.Equal(t, 0, db.deletes, "dry run must not delete anything")
+	}
+
+	// An invalid dry_run value is rejected.
+	req, err := http.NewRequest(http.MethodGet, "?match[]=test_metric1&dry_run=xxx", nil)
+	require.NoError(t, err)
+	res := api.deleteSeries(req)
+	assertAPIError(t, res.err, errorBadData)
+	require.Equal(t, 0, db.deletes)
+
+	// Without dry_run the deletion is carried out as before.
+	req, err = http.NewRequest(http.MethodGet, "?match[]=test_metric1", nil)
+	require.NoError(t, err)
+	res = api.deleteSeries(req)
+	assertAPIError(t, res.err, errorNone)
+	require.Equal(t, 1, db.deletes)
+}
+
 func TestAdminEndpoints(t *testing.T) {
 	tsdb, tsdbWithError, tsdbNotReady := &fakeDB{}, &fakeDB{err: errors.New("some error")}, &fakeDB{err: fmt.Errorf("wrap: %w", tsdb.ErrNotReady)}
 	snapshotAPI := func(api *API) apiFunc { return api.snapshot }
@@ -3954,6 +4014,23 @@ func TestParseTimeParam(t *testing.T) {
 	}
 }
 
+func TestAlignToStep(t *testing.T) {
+	step := 30 * time.Second
+	unalignedStart := time.Unix(103, 0)
+	unalignedEnd := time.Unix(287, 0)
+
+	// Unaligned timestamps are snapped down to multiples of the step
+	// relative to the Unix epoch.
+	require.Equal(t, time.Unix(90, 0), alignToStep(unalignedStart, step))
+	require.Equal(t, time.Unix(270, 0), alignToStep(unalignedEnd, step))
+
+	// Already aligned timestamps pass through unchanged.
+	require.Equal(t, time.Unix(90, 0), alignToStep(time.Unix(90, 0), step))
+
+	// Sub-second steps align on sub-second boundaries.
+	require.Equal(t, time.Unix(10, 500e6), alignToStep(time.Unix(10, 600e6), 500*time.Millisecond))
+}
+
 func TestParseTime(t *testing.T) {
 	ts, err := time.Parse(time.RFC3339Nano, "2015-06-03T13:21:58.555Z")
 	if err != nil {
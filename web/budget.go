@@ -0,0 +1,114 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package web
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queryBudgetWindow is the sliding window over which per-client query cost
+// is accounted before it expires.
+const queryBudgetWindow = time.Minute
+
+// clientCharge records query execution time attributed to a client at a
+// point in time.
+type clientCharge struct {
+	ts   time.Time
+	cost time.Duration
+}
+
+// queryBudget tracks query execution time per client address over a sliding
+// window and rejects requests from clients that exceeded their budget.
+type queryBudget struct {
+	mtx    sync.Mutex
+	budget time.Duration
+	window time.Duration
+	usage  map[string][]clientCharge
+
+	now func() time.Time
+}
+
+func newQueryBudget(budget, window time.Duration) *queryBudget {
+	return &queryBudget{
+		budget: budget,
+		window: window,
+		usage:  map[string][]clientCharge{},
+		now:    time.Now,
+	}
+}
+
+// used returns the query time charged to addr within the window, pruning
+// expired charges as a side effect.
+func (b *queryBudget) used(addr string, now time.Time) time.Duration {
+	charges := b.usage[addr]
+	cutoff := now.Add(-b.window)
+	for len(charges) > 0 && charges[0].ts.Before(cutoff) {
+		charges = charges[1:]
+	}
+	if len(charges) == 0 {
+		delete(b.usage, addr)
+	} else {
+		b.usage[addr] = charges
+	}
+	var total time.Duration
+	for _, c := range charges {
+		total += c.cost
+	}
+	return total
+}
+
+func (b *queryBudget) charge(addr string, cost time.Duration) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.usage[addr] = append(b.usage[addr], clientCharge{ts: b.now(), cost: cost})
+}
+
+// exceeded reports whether addr has used up its budget within the window.
+func (b *queryBudget) exceeded(addr string) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.used(addr, b.now()) > b.budget
+}
+
+// clientAddr extracts the client host from a request's remote address.
+func clientAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// wrap instruments query endpoints of the given handler with per-client
+// budget accounting. Requests from clients over budget are rejected with
+// 429 Too Many Requests.
+func (b *queryBudget) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/query") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		addr := clientAddr(r)
+		if b.exceeded(addr) {
+			http.Error(w, "query budget exceeded, try again later", http.StatusTooManyRequests)
+			return
+		}
+		start := b.now()
+		next.ServeHTTP(w, r)
+		b.charge(addr, b.now().Sub(start))
+	})
+}
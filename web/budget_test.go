@@ -0,0 +1,64 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryBudget(t *testing.T) {
+	// Use a fake clock so query cost and charge expiry are deterministic.
+	now := time.Unix(0, 0)
+	b := newQueryBudget(30*time.Second, time.Minute)
+	b.now = func() time.Time { return now }
+
+	handler := b.wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// Each query "takes" 20 seconds of the fake clock.
+		now = now.Add(20 * time.Second)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	query := func(addr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/query?query=up", nil)
+		req.RemoteAddr = addr + ":12345"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The first two queries fit into the budget, the third exceeds it.
+	require.Equal(t, http.StatusOK, query("192.0.2.1"))
+	require.Equal(t, http.StatusOK, query("192.0.2.1"))
+	require.Equal(t, http.StatusTooManyRequests, query("192.0.2.1"))
+
+	// A different client is unaffected.
+	require.Equal(t, http.StatusOK, query("192.0.2.2"))
+
+	// Non-query endpoints are never rejected.
+	req := httptest.NewRequest(http.MethodGet, "/labels", nil)
+	req.RemoteAddr = "192.0.2.1:12345"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Once the window has passed, the charges expire and the client may
+	// query again.
+	now = now.Add(2 * time.Minute)
+	require.Equal(t, http.StatusOK, query("192.0.2.1"))
+}
@@ -129,6 +129,16 @@ func withStackTracer(h http.Handler, l *slog.Logger) http.Handler {
 	})
 }
 
+// withResponseHeaders adds the given static headers to every response.
+func withResponseHeaders(h http.Handler, headers map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 type metrics struct {
 	requestCounter  *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
@@ -274,6 +284,7 @@ type Options struct {
 	CORSOrigin                 *regexp.Regexp
 	ReadTimeout                time.Duration
 	MaxConnections             int
+	MaxConnectionsPerIP        int
 	ExternalURL                *url.URL
 	RoutePrefix                string
 	UseLocalAssets             bool
@@ -287,8 +298,11 @@ type Options struct {
 	RemoteReadSampleLimit      int
 	RemoteReadConcurrencyLimit int
 	RemoteReadBytesInFrame     int
+	PerClientQueryBudget       time.Duration
+	ResponseHeaders            map[string]string
 	EnableRemoteWriteReceiver  bool
 	EnableOTLPWriteReceiver    bool
+	AlignStepsToEpoch          bool
 	IsAgent                    bool
 	AppName                    string
 
@@ -386,6 +400,7 @@ func New(logger *slog.Logger, o *Options) *Handler {
 		o.EnableRemoteWriteReceiver,
 		o.AcceptRemoteWriteProtoMsgs,
 		o.EnableOTLPWriteReceiver,
+		o.AlignStepsToEpoch,
 	)
 
 	if o.RoutePrefix != "/" {
@@ -635,8 +650,9 @@ func (h *Handler) Reload() <-chan chan error {
 func (h *Handler) Listeners() ([]net.Listener, error) {
 	var listeners []net.Listener
 	sem := netconnlimit.NewSharedSemaphore(h.options.MaxConnections)
+	perHost := netconnlimit.NewSharedPerHostCounter(h.options.MaxConnectionsPerIP)
 	for _, address := range h.options.ListenAddresses {
-		listener, err := h.Listener(address, sem)
+		listener, err := h.Listener(address, sem, perHost)
 		if err != nil {
 			return listeners, err
 		}
@@ -646,7 +662,7 @@ func (h *Handler) Listeners() ([]net.Listener, error) {
 }
 
 // Listener creates the TCP listener for web requests.
-func (h *Handler) Listener(address string, sem chan struct{}) (net.Listener, error) {
+func (h *Handler) Listener(address string, sem chan struct{}, perHost *netconnlimit.PerHostCounter) (net.Listener, error) {
 	h.logger.Info("Start listening for connections", "address", address)
 
 	listener, err := net.Listen("tcp", address)
@@ -654,6 +670,7 @@ func (h *Handler) Listener(address string, sem chan struct{}) (net.Listener, err
 		return listener, err
 	}
 	listener = netconnlimit.SharedLimitListener(listener, sem)
+	listener = netconnlimit.PerHostLimitListener(listener, perHost)
 
 	// Monitor incoming connections with conntrack.
 	listener = conntrack.NewListener(listener,
@@ -686,7 +703,12 @@ func (h *Handler) Run(ctx context.Context, listeners []net.Listener, webConfig s
 		WithInstrumentation(setPathWithPrefix(apiPath + "/v1"))
 	h.apiV1.Register(av1)
 
-	mux.Handle(apiPath+"/v1/", http.StripPrefix(apiPath+"/v1", av1))
+	var av1Handler http.Handler = av1
+	if h.options.PerClientQueryBudget > 0 {
+		av1Handler = newQueryBudget(h.options.PerClientQueryBudget, queryBudgetWindow).wrap(av1Handler)
+	}
+
+	mux.Handle(apiPath+"/v1/", http.StripPrefix(apiPath+"/v1", av1Handler))
 
 	errlog := slog.NewLogLogger(h.logger.Handler(), slog.LevelError)
 
@@ -694,8 +716,13 @@ func (h *Handler) Run(ctx context.Context, listeners []net.Listener, webConfig s
 		return fmt.Sprintf("%s %s", r.Method, r.URL.Path)
 	})
 
+	var muxHandler http.Handler = mux
+	if len(h.options.ResponseHeaders) > 0 {
+		muxHandler = withResponseHeaders(muxHandler, h.options.ResponseHeaders)
+	}
+
 	httpSrv := &http.Server{
-		Handler:     withStackTracer(otelhttp.NewHandler(mux, "", spanNameFormatter), h.logger),
+		Handler:     withStackTracer(otelhttp.NewHandler(muxHandler, "", spanNameFormatter), h.logger),
 		ErrorLog:    errlog,
 		ReadTimeout: h.options.ReadTimeout,
 	}
@@ -321,6 +321,32 @@ func TestDebugHandler(t *testing.T) {
 	}
 }
 
+func TestResponseHeaders(t *testing.T) {
+	opts := &Options{
+		RoutePrefix:     "/",
+		ListenAddresses: []string{"somehost:9090"},
+		ExternalURL: &url.URL{
+			Host:   "localhost.localdomain:9090",
+			Scheme: "http",
+		},
+		ResponseHeaders: map[string]string{
+			"Strict-Transport-Security": "max-age=63072000",
+			"Content-Security-Policy":   "default-src 'self'",
+		},
+	}
+	handler := New(nil, opts)
+	handler.SetReady(Ready)
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/graph", nil)
+	require.NoError(t, err)
+
+	withResponseHeaders(handler.router, opts.ResponseHeaders).ServeHTTP(w, req)
+
+	require.Equal(t, "max-age=63072000", w.Header().Get("Strict-Transport-Security"))
+	require.Equal(t, "default-src 'self'", w.Header().Get("Content-Security-Policy"))
+}
+
 func TestHTTPMetrics(t *testing.T) {
 	t.Parallel()
 	handler := New(nil, &Options{